@@ -0,0 +1,330 @@
+// Package availability computes host and service availability from the
+// state history held in the Livestatus log table.
+package availability
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+var logColumns = []string{"time", "lineno", "class", "type", "options", "message"}
+
+// Treatment sets how time spent in acknowledged or flapping periods is
+// accounted for.
+type Treatment int
+
+const (
+	// AsState counts the period in the actual state of the object.
+	AsState Treatment = iota
+	// AsOK counts the period as OK, or UP for hosts.
+	AsOK
+	// Excluded leaves the period out of the state durations altogether.
+	Excluded
+)
+
+// Options selects the objects and time range to compute availability for.
+type Options struct {
+	Start time.Time
+	End   time.Time
+
+	// Filters are extra log table filters selecting the objects, such as
+	// `host_name = db1`.
+	Filters []string
+
+	// Lookback is how long before Start to search for the state objects
+	// were in when the range starts. Nagios logs the current state of every
+	// object when rotating its log, so this should cover at least one
+	// rotation. It defaults to 24 hours.
+	Lookback time.Duration
+
+	// SoftStates also accounts for soft state changes, rather than only
+	// hard ones.
+	SoftStates bool
+
+	Acknowledged Treatment
+	Flapping     Treatment
+}
+
+// Result is the time a host or service spent in each state over the range.
+// Service is empty for hosts. Time spent in scheduled downtime, or with no
+// known state, is not included in States.
+type Result struct {
+	Host    string
+	Service string
+
+	States       map[int64]time.Duration
+	Downtime     time.Duration
+	Unmonitored  time.Duration
+	Acknowledged time.Duration
+	Flapping     time.Duration
+}
+
+// Availability returns the fraction of the accounted time spent in the OK,
+// or UP, state.
+func (r Result) Availability() float64 {
+	var total time.Duration
+	for _, d := range r.States {
+		total += d
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(r.States[0]) / float64(total)
+}
+
+// Calculate queries the log table and computes the availability of every
+// object matching the options over their time range.
+func Calculate(ctx context.Context, ls *lvst.Livestatus, opts Options) ([]Result, error) {
+	if !opts.End.After(opts.Start) {
+		return nil, fmt.Errorf("invalid availability range %s - %s", opts.Start, opts.End)
+	}
+	lookback := opts.Lookback
+	if lookback == 0 {
+		lookback = 24 * time.Hour
+	}
+
+	q := ls.Query("log")
+	q.Columns(logColumns...)
+	q.Filter(fmt.Sprintf("time >= %d", opts.Start.Add(-lookback).Unix()))
+	q.Filter(fmt.Sprintf("time <= %d", opts.End.Unix()))
+
+	// State changes of the selected objects
+	q.Filter(fmt.Sprintf("class = %d", lvst.LogClassAlert))
+	q.Filter(fmt.Sprintf("class = %d", lvst.LogClassState))
+	q.Or(2)
+	for _, f := range opts.Filters {
+		q.Filter(f)
+	}
+	q.And(len(opts.Filters) + 1)
+
+	// Acknowledgements, which are only logged as external commands
+	q.Filter(fmt.Sprintf("class = %d", lvst.LogClassCommand))
+	q.Filter("options ~ ^(ACKNOWLEDGE|REMOVE)_(HOST|SVC)_")
+	q.And(2)
+	q.Or(2)
+
+	resp, err := q.ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var evs []lvst.LogEvent
+	for _, r := range resp.Records {
+		ev, err := lvst.ParseLogEvent(r)
+		if err != nil {
+			return nil, err
+		}
+		evs = append(evs, ev)
+	}
+
+	return Compute(evs, opts), nil
+}
+
+// Compute calculates availability over the options time range from log
+// events. Events may be given in any order, and should start early enough
+// to establish the state of each object at the start of the range.
+func Compute(evs []lvst.LogEvent, opts Options) []Result {
+	sorted := append([]lvst.LogEvent{}, evs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ei, ej := sorted[i].Entry(), sorted[j].Entry()
+		if !ei.Time.Equal(ej.Time) {
+			return ei.Time.Before(ej.Time)
+		}
+		return ei.LineNo < ej.LineNo
+	})
+
+	objs := map[object]*tracker{}
+	get := func(host, svc string, create bool) *tracker {
+		o := object{host, svc}
+		t, ok := objs[o]
+		if !ok && create {
+			t = &tracker{
+				opts:  opts,
+				state: -1,
+				last:  opts.Start,
+				res: Result{
+					Host:    host,
+					Service: svc,
+					States:  map[int64]time.Duration{},
+				},
+			}
+			objs[o] = t
+		}
+		return t
+	}
+
+	for _, ev := range sorted {
+		ts := ev.Entry().Time
+		if ts.After(opts.End) {
+			break
+		}
+
+		switch e := ev.(type) {
+		case lvst.HostAlert:
+			if e.StateType == "SOFT" && !opts.SoftStates {
+				continue
+			}
+			t := get(e.Host, "", true)
+			t.advance(ts)
+			t.setState(e.State)
+		case lvst.ServiceAlert:
+			if e.StateType == "SOFT" && !opts.SoftStates {
+				continue
+			}
+			t := get(e.Host, e.Service, true)
+			t.advance(ts)
+			t.setState(e.State)
+		case lvst.DowntimeStart:
+			if t := get(e.Host, e.Service, false); t != nil {
+				t.advance(ts)
+				t.downtime = true
+			}
+		case lvst.DowntimeStop:
+			if t := get(e.Host, e.Service, false); t != nil {
+				t.advance(ts)
+				t.downtime = false
+			}
+		case lvst.FlappingStart:
+			if t := get(e.Host, e.Service, false); t != nil {
+				t.advance(ts)
+				t.flapping = true
+			}
+		case lvst.FlappingStop:
+			if t := get(e.Host, e.Service, false); t != nil {
+				t.advance(ts)
+				t.flapping = false
+			}
+		case lvst.ExternalCommand:
+			applyAck(e, ts, func(host, svc string) *tracker { return get(host, svc, false) })
+		}
+	}
+
+	var res []Result
+	for _, t := range objs {
+		t.advance(opts.End)
+		res = append(res, t.res)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Host != res[j].Host {
+			return res[i].Host < res[j].Host
+		}
+		return res[i].Service < res[j].Service
+	})
+
+	return res
+}
+
+// applyAck tracks acknowledgements from the external commands setting and
+// removing them.
+func applyAck(e lvst.ExternalCommand, ts time.Time, get func(host, svc string) *tracker) {
+	var (
+		t      *tracker
+		sticky bool
+		acked  bool
+	)
+
+	switch e.Command {
+	case "ACKNOWLEDGE_HOST_PROBLEM":
+		if len(e.Args) < 2 {
+			return
+		}
+		t, sticky, acked = get(e.Args[0], ""), e.Args[1] == "2", true
+	case "ACKNOWLEDGE_SVC_PROBLEM":
+		if len(e.Args) < 3 {
+			return
+		}
+		t, sticky, acked = get(e.Args[0], e.Args[1]), e.Args[2] == "2", true
+	case "REMOVE_HOST_ACKNOWLEDGEMENT":
+		if len(e.Args) < 1 {
+			return
+		}
+		t = get(e.Args[0], "")
+	case "REMOVE_SVC_ACKNOWLEDGEMENT":
+		if len(e.Args) < 2 {
+			return
+		}
+		t = get(e.Args[0], e.Args[1])
+	}
+	if t == nil {
+		return
+	}
+
+	t.advance(ts)
+	// Only problems can be acknowledged
+	t.acked = acked && t.state > 0
+	t.ackSticky = sticky
+}
+
+type object struct {
+	host, service string
+}
+
+// tracker follows the state of a single object through the log.
+type tracker struct {
+	opts Options
+	res  Result
+	last time.Time
+
+	state     int64
+	downtime  bool
+	flapping  bool
+	acked     bool
+	ackSticky bool
+}
+
+func (t *tracker) setState(s int64) {
+	// Acknowledgements are removed on any state change, or on recovery
+	// when sticky
+	if t.acked && s != t.state && (!t.ackSticky || s == 0) {
+		t.acked = false
+	}
+	t.state = s
+}
+
+// advance accounts for the time between the last event and ts, clamped to
+// the options time range.
+func (t *tracker) advance(ts time.Time) {
+	if ts.After(t.opts.End) {
+		ts = t.opts.End
+	}
+	if !ts.After(t.last) {
+		return
+	}
+	d := ts.Sub(t.last)
+	t.last = ts
+
+	switch {
+	case t.state < 0:
+		t.res.Unmonitored += d
+		return
+	case t.downtime:
+		t.res.Downtime += d
+		return
+	}
+
+	if t.acked {
+		t.res.Acknowledged += d
+	}
+	if t.flapping {
+		t.res.Flapping += d
+	}
+
+	treatment := AsState
+	if t.acked {
+		treatment = t.opts.Acknowledged
+	}
+	if t.flapping && treatment == AsState {
+		treatment = t.opts.Flapping
+	}
+
+	switch treatment {
+	case AsOK:
+		t.res.States[0] += d
+	case AsState:
+		t.res.States[t.state] += d
+	}
+}
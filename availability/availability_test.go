@@ -0,0 +1,178 @@
+package availability
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+func entry(ts int64) lvst.LogEntry {
+	return lvst.LogEntry{Time: time.Unix(ts, 0)}
+}
+
+var events = []lvst.LogEvent{
+	lvst.HostAlert{LogEntry: entry(500), Host: "db1", State: 0, StateType: "HARD", Initial: true},
+	lvst.ServiceAlert{LogEntry: entry(1100), Host: "db1", Service: "Disk", State: 2, StateType: "HARD"},
+	lvst.ServiceAlert{LogEntry: entry(1150), Host: "db1", Service: "Disk", State: 0, StateType: "SOFT"},
+	lvst.HostAlert{LogEntry: entry(1200), Host: "db1", State: 1, StateType: "HARD"},
+	lvst.ExternalCommand{LogEntry: entry(1200), Command: "ACKNOWLEDGE_SVC_PROBLEM", Args: []string{"db1", "Disk", "2", "0", "1", "admin", "on it"}},
+	lvst.ExternalCommand{LogEntry: entry(1300), Command: "ACKNOWLEDGE_HOST_PROBLEM", Args: []string{"db1", "1", "0", "1", "admin", "on it"}},
+	lvst.HostAlert{LogEntry: entry(1400), Host: "db1", State: 2, StateType: "HARD"},
+	lvst.HostAlert{LogEntry: entry(1500), Host: "db1", State: 0, StateType: "HARD"},
+	lvst.DowntimeStart{LogEntry: entry(1600), Host: "db1"},
+	lvst.DowntimeStop{LogEntry: entry(1700), Host: "db1"},
+	lvst.FlappingStart{LogEntry: entry(1800), Host: "db1"},
+	lvst.ServiceAlert{LogEntry: entry(1900), Host: "db1", Service: "Disk", State: 0, StateType: "HARD"},
+	lvst.HostAlert{LogEntry: entry(2100), Host: "db1", State: 1, StateType: "HARD"},
+}
+
+func Test_Compute(t *testing.T) {
+	opts := Options{
+		Start: time.Unix(1000, 0),
+		End:   time.Unix(2000, 0),
+	}
+
+	expected := []Result{
+		Result{
+			Host: "db1",
+			States: map[int64]time.Duration{
+				0: 600 * time.Second,
+				1: 200 * time.Second,
+				2: 100 * time.Second,
+			},
+			Downtime:     100 * time.Second,
+			Acknowledged: 100 * time.Second,
+			Flapping:     200 * time.Second,
+		},
+		Result{
+			Host:    "db1",
+			Service: "Disk",
+			States: map[int64]time.Duration{
+				0: 100 * time.Second,
+				2: 800 * time.Second,
+			},
+			Unmonitored:  100 * time.Second,
+			Acknowledged: 700 * time.Second,
+		},
+	}
+
+	result := Compute(events, opts)
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	if a := result[0].Availability(); a != 600.0/900.0 {
+		t.Logf("\nExpected availability %f\nbut got  %f\n", 600.0/900.0, a)
+		t.Fail()
+	}
+}
+
+func Test_ComputeTreatments(t *testing.T) {
+	opts := Options{
+		Start:        time.Unix(1000, 0),
+		End:          time.Unix(2000, 0),
+		Acknowledged: AsOK,
+		Flapping:     Excluded,
+	}
+
+	result := Compute(events, opts)
+
+	expected := map[int64]time.Duration{
+		0: 500 * time.Second,
+		1: 100 * time.Second,
+		2: 100 * time.Second,
+	}
+	if !reflect.DeepEqual(result[0].States, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result[0].States)
+		t.Fail()
+	}
+
+	expected = map[int64]time.Duration{
+		0: 800 * time.Second,
+		2: 100 * time.Second,
+	}
+	if !reflect.DeepEqual(result[1].States, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result[1].States)
+		t.Fail()
+	}
+
+	// The soft recovery happens before the acknowledgement, which is then
+	// ignored
+	opts.SoftStates = true
+	result = Compute(events, opts)
+
+	expected = map[int64]time.Duration{
+		0: 850 * time.Second,
+		2: 50 * time.Second,
+	}
+	if !reflect.DeepEqual(result[1].States, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result[1].States)
+		t.Fail()
+	}
+}
+
+func Test_Calculate(t *testing.T) {
+	var req string
+	ls := lvst.NewLivestatusWithDialer(func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			rd := bufio.NewReader(server)
+			for {
+				line, err := rd.ReadString('\n')
+				if err != nil || line == "\n" {
+					break
+				}
+				req += line
+			}
+			body := `[[500,1,6,"CURRENT HOST STATE","db1;UP;HARD;1;PING OK","[500] CURRENT HOST STATE: db1;UP;HARD;1;PING OK"]]` + "\n"
+			fmt.Fprintf(server, "200 %11d\n%s", len(body), body)
+		}()
+		return client, nil
+	})
+
+	result, err := Calculate(context.Background(), ls, Options{
+		Start:   time.Unix(1000, 0),
+		End:     time.Unix(2000, 0),
+		Filters: []string{"host_name = db1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 1 || result[0].States[0] != 1000*time.Second {
+		t.Logf("\nUnexpected result %#v\n", result)
+		t.Fail()
+	}
+
+	expected := "GET log\n"
+	expected += "Columns: time lineno class type options message\n"
+	expected += "Filter: time >= -85400\n"
+	expected += "Filter: time <= 2000\n"
+	expected += "Filter: class = 1\n"
+	expected += "Filter: class = 6\n"
+	expected += "Or: 2\n"
+	expected += "Filter: host_name = db1\n"
+	expected += "And: 2\n"
+	expected += "Filter: class = 5\n"
+	expected += "Filter: options ~ ^(ACKNOWLEDGE|REMOVE)_(HOST|SVC)_\n"
+	expected += "And: 2\n"
+	expected += "Or: 2\n"
+	if !strings.HasPrefix(req, expected) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expected, req)
+		t.Fail()
+	}
+
+	if _, err = Calculate(context.Background(), ls, Options{}); err == nil {
+		t.Log("\nExpected error for empty range\n")
+		t.Fail()
+	}
+}
@@ -0,0 +1,188 @@
+package livestatus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	circuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "livestatus_circuit_state",
+		Help: "State of the circuit of each livestatus backend, 0 closed, 1 open and 2 half-open",
+	}, []string{"backend"})
+
+	circuitOpenCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "livestatus_circuit_open_count",
+		Help: "Count of the times the circuit of a livestatus backend opened",
+	}, []string{"backend"})
+
+	circuitRejectCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "livestatus_circuit_reject_count",
+		Help: "Count of the queries and commands failed fast while the circuit of a livestatus backend is open",
+	}, []string{"backend"})
+)
+
+func init() {
+	prometheus.MustRegister(circuitState)
+	prometheus.MustRegister(circuitOpenCount)
+	prometheus.MustRegister(circuitRejectCount)
+}
+
+// CircuitState is the health state of a backend tracked by a Breaker.
+type CircuitState int
+
+// Circuit states
+const (
+	// CircuitClosed lets requests through, the backend being healthy.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails requests fast, the backend being unavailable.
+	CircuitOpen
+	// CircuitHalfOpen fails requests fast while the backend is probed.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// Breaker tracks the health of a backend, opening its circuit after
+// consecutive failures of the queries and commands sent to it. While open,
+// requests fail fast with a CircuitOpenError, and the backend is probed with
+// a status query at most once per probe interval, closing the circuit once
+// a probe succeeds. A breaker may be shared by the instances connected to
+// the same backend.
+type Breaker struct {
+	// Threshold is the number of consecutive failures opening the circuit,
+	// 5 by default.
+	Threshold int
+	// ProbeInterval is the minimum delay between probes, 10s by default.
+	ProbeInterval time.Duration
+
+	name string
+	now  func() time.Time
+
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	lastErr   error
+	openedAt  time.Time
+	lastProbe time.Time
+}
+
+// NewBreaker creates a closed breaker for the named backend.
+func NewBreaker(backend string) *Breaker {
+	circuitState.WithLabelValues(backend).Set(float64(CircuitClosed))
+	return &Breaker{name: backend, now: time.Now}
+}
+
+// SetBreaker sets the breaker tracking the health of the backend of the
+// instance, nil disabling it.
+func (l *Livestatus) SetBreaker(b *Breaker) {
+	l.breaker = b
+}
+
+// State returns the state of the circuit.
+func (b *Breaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow returns nil when a request may be sent to the backend, probing it
+// through the instance when the circuit is open and a probe is due.
+func (b *Breaker) allow(ctx context.Context, l *Livestatus) error {
+	b.mu.Lock()
+	if b.state == CircuitClosed {
+		b.mu.Unlock()
+		return nil
+	}
+
+	interval := b.ProbeInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	if b.state == CircuitHalfOpen || b.now().Sub(b.lastProbe) < interval {
+		err := &CircuitOpenError{Backend: b.name, Since: b.openedAt, Err: b.lastErr}
+		b.mu.Unlock()
+		circuitRejectCount.WithLabelValues(b.name).Inc()
+		return err
+	}
+
+	b.setState(CircuitHalfOpen)
+	b.lastProbe = b.now()
+	b.mu.Unlock()
+
+	_, err := newQuery("status", l).Columns("program_start").exec(ctx)
+	if err != nil && ctx.Err() == nil {
+		b.record(err)
+		return &CircuitOpenError{Backend: b.name, Since: b.openedAt, Err: err}
+	}
+	if err != nil {
+		// The probe was interrupted, leaving the health unknown
+		b.mu.Lock()
+		b.setState(CircuitOpen)
+		b.mu.Unlock()
+		return err
+	}
+
+	b.record(nil)
+	return nil
+}
+
+// record updates the health of the backend from the outcome of a request.
+// Livestatus error statuses, and requests cancelled by their caller, tell
+// nothing about it, while deadlines exceeded count as the backend failing to
+// answer in time.
+func (b *Breaker) record(err error) {
+	var serr *StatusError
+	if errors.As(err, &serr) || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		b.lastErr = nil
+		b.setState(CircuitClosed)
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+
+	threshold := b.Threshold
+	if threshold <= 0 {
+		threshold = 5
+	}
+	switch {
+	case b.state == CircuitHalfOpen:
+		b.setState(CircuitOpen)
+	case b.state == CircuitClosed && b.failures >= threshold:
+		b.openedAt = b.now()
+		b.lastProbe = b.now()
+		b.setState(CircuitOpen)
+		circuitOpenCount.WithLabelValues(b.name).Inc()
+	}
+}
+
+// setState changes the state of the circuit. It must be called with the lock
+// held.
+func (b *Breaker) setState(s CircuitState) {
+	b.state = s
+	circuitState.WithLabelValues(b.name).Set(float64(s))
+}
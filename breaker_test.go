@@ -0,0 +1,107 @@
+package livestatus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_Breaker(t *testing.T) {
+	fixtures := map[string]string{
+		"hosts":  `[["name"],["db1"]]`,
+		"status": `[["program_start"],[1500000000]]`,
+	}
+	ls, dials := flakyLivestatus(3, fixtureHandler(fixtures))
+
+	now := time.Unix(1500000000, 0)
+	b := NewBreaker("test")
+	b.Threshold = 2
+	b.ProbeInterval = time.Minute
+	b.now = func() time.Time { return now }
+	ls.SetBreaker(b)
+
+	for i := 0; i < 2; i++ {
+		if _, err := ls.Query("hosts").Exec(); err == nil {
+			t.Fatalf("expected failure %d", i)
+		}
+	}
+	if b.State() != CircuitOpen {
+		t.Logf("\nExpected %v\nbut got  %v\n", CircuitOpen, b.State())
+		t.Fail()
+	}
+
+	// Fail fast without dialing
+	_, err := ls.Query("hosts").Exec()
+	if coe, ok := err.(*CircuitOpenError); !ok || coe.Backend != "test" || !coe.Since.Equal(now) || dials() != 2 {
+		t.Logf("\nExpected a CircuitOpenError after 2 dials\nbut got  %#v after %d\n", err, dials())
+		t.Fail()
+	}
+	c := ls.Command()
+	c.Raw("DISABLE_NOTIFICATIONS")
+	if _, err := c.Exec(); err == nil {
+		t.Logf("\nExpected commands to fail fast\n")
+		t.Fail()
+	}
+
+	// A failed probe keeps the circuit open
+	now = now.Add(time.Minute)
+	if _, err := ls.Query("hosts").Exec(); err == nil || b.State() != CircuitOpen || dials() != 3 {
+		t.Logf("\nExpected a failed probe after 3 dials\nbut got  %#v, %v after %d\n", err, b.State(), dials())
+		t.Fail()
+	}
+
+	// A successful probe closes it
+	now = now.Add(time.Minute)
+	resp, err := ls.Query("hosts").Exec()
+	if err != nil || len(resp.Records) != 1 || b.State() != CircuitClosed {
+		t.Logf("\nExpected the circuit to close\nbut got  %#v, %v\n", err, b.State())
+		t.Fail()
+	}
+}
+
+func Test_BreakerStatusError(t *testing.T) {
+	ls, _ := flakyLivestatus(0, fixtureHandler(nil))
+	b := NewBreaker("test")
+	b.Threshold = 1
+	ls.SetBreaker(b)
+
+	// Error statuses tell the backend is up
+	if _, err := ls.Query("hosts").Exec(); err == nil || b.State() != CircuitClosed {
+		t.Logf("\nExpected %v\nbut got  %v\n", CircuitClosed, b.State())
+		t.Fail()
+	}
+}
+
+func Test_BreakerIgnoredErrors(t *testing.T) {
+	b := NewBreaker("test")
+	b.Threshold = 1
+
+	for _, err := range []error{
+		fmt.Errorf("query failed, %w", &StatusError{Status: 404, Message: "no table"}),
+		context.Canceled,
+		fmt.Errorf("query failed, %w", context.Canceled),
+	} {
+		b.record(err)
+		if b.State() != CircuitClosed {
+			t.Logf("\nExpected %v after %v\nbut got  %v\n", CircuitClosed, err, b.State())
+			t.Fail()
+		}
+	}
+
+	b.record(errors.New("connection refused"))
+	if b.State() != CircuitOpen {
+		t.Logf("\nExpected %v\nbut got  %v\n", CircuitOpen, b.State())
+		t.Fail()
+	}
+
+	// Backends failing to answer in time are counted
+	b = NewBreaker("test")
+	b.Threshold = 1
+	b.record(fmt.Errorf("query failed, %w", context.DeadlineExceeded))
+	if b.State() != CircuitOpen {
+		t.Logf("\nExpected %v after a deadline exceeded\nbut got  %v\n", CircuitOpen, b.State())
+		t.Fail()
+	}
+}
@@ -0,0 +1,240 @@
+package livestatus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cacheHitCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "livestatus_cache_hit_count",
+		Help: "Count of the livestatus queries answered from the cache",
+	}, []string{"table"})
+
+	cacheMissCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "livestatus_cache_miss_count",
+		Help: "Count of the cacheable livestatus queries sent to the server",
+	}, []string{"table"})
+
+	cacheSharedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "livestatus_cache_shared_count",
+		Help: "Count of the livestatus queries sharing the response of an identical query in progress",
+	}, []string{"table"})
+)
+
+func init() {
+	prometheus.MustRegister(cacheHitCount)
+	prometheus.MustRegister(cacheMissCount)
+	prometheus.MustRegister(cacheSharedCount)
+}
+
+// Cache holds query responses for a time to live set per table. Responses
+// are keyed on the request text, and concurrent identical queries share a
+// single request to the server.
+//
+// Records of cached responses are shared between queries and must not be
+// modified.
+type Cache struct {
+	mu         sync.Mutex
+	defaultTTL time.Duration
+	ttls       map[string]time.Duration
+	entries    map[string]*cacheEntry
+	calls      map[string]*cacheCall
+	// gen is incremented on each invalidation, so that responses to queries
+	// started before are not stored
+	gen uint64
+
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	table   string
+	resp    *Response
+	expires time.Time
+}
+
+type cacheCall struct {
+	done chan struct{}
+	resp *Response
+	err  error
+	// waiters is the number of queries sharing the call
+	waiters int
+}
+
+// NewCache creates a new cache, keeping responses for ttl unless set
+// otherwise for their table. A ttl of 0 only caches the tables given a TTL.
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		defaultTTL: ttl,
+		ttls:       map[string]time.Duration{},
+		entries:    map[string]*cacheEntry{},
+		calls:      map[string]*cacheCall{},
+		now:        time.Now,
+	}
+}
+
+// SetTTL sets the time to live of the responses of a table, 0 disabling
+// caching for it.
+func (c *Cache) SetTTL(table string, ttl time.Duration) *Cache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttls[table] = ttl
+	return c
+}
+
+// Invalidate drops the cached responses of the given tables, and those of
+// the tables starting with their names, such as hostsbygroup for hosts. All
+// responses are dropped when no table is given.
+func (c *Cache) Invalidate(tables ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for key, e := range c.entries {
+		if len(tables) == 0 || hasAnyPrefix(e.table, tables) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *Cache) ttl(table string) time.Duration {
+	if ttl, ok := c.ttls[table]; ok {
+		return ttl
+	}
+	return c.defaultTTL
+}
+
+// get returns the response to a query, from the cache or from a request
+// shared with identical queries in progress.
+func (c *Cache) get(ctx context.Context, q *Query) (*Response, error) {
+	key := q.ls.network + " " + q.ls.address + "\n" + q.buildCmd()
+	if q.rowsOnly {
+		key += "rows"
+	}
+
+	c.mu.Lock()
+	ttl := c.ttl(q.table)
+	if ttl <= 0 {
+		c.mu.Unlock()
+		return q.send(ctx)
+	}
+
+	if e, ok := c.entries[key]; ok {
+		if c.now().Before(e.expires) {
+			c.mu.Unlock()
+			cacheHitCount.WithLabelValues(q.table).Inc()
+			return copyResponse(e.resp), nil
+		}
+		delete(c.entries, key)
+	}
+
+	if call, ok := c.calls[key]; ok {
+		call.waiters++
+		c.mu.Unlock()
+		cacheSharedCount.WithLabelValues(q.table).Inc()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.err != nil {
+			// The query may have been interrupted by its caller only
+			if isContextErr(call.err) && ctx.Err() == nil {
+				return c.get(ctx, q)
+			}
+			return nil, call.err
+		}
+		return copyResponse(call.resp), nil
+	}
+
+	call := &cacheCall{done: make(chan struct{})}
+	c.calls[key] = call
+	gen := c.gen
+	c.mu.Unlock()
+
+	cacheMissCount.WithLabelValues(q.table).Inc()
+	call.resp, call.err = q.send(ctx)
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	if call.err == nil && gen == c.gen {
+		c.expire()
+		c.entries[key] = &cacheEntry{
+			table:   q.table,
+			resp:    call.resp,
+			expires: c.now().Add(ttl),
+		}
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	return copyResponse(call.resp), nil
+}
+
+// expire drops the expired entries. It must be called with the lock held.
+func (c *Cache) expire() {
+	now := c.now()
+	for key, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func copyResponse(resp *Response) *Response {
+	return &Response{
+		Status:  resp.Status,
+		Records: append([]Record(nil), resp.Records...),
+		Columns: resp.Columns,
+		Types:   resp.Types,
+		Rows:    append([][]interface{}(nil), resp.Rows...),
+	}
+}
+
+// commandTables returns the tables whose data may be changed by an external
+// command, or nil when it may change any of them, as for global commands,
+// which take no arguments.
+func commandTables(cmd string, args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+
+	// Commands are logged, and may change states
+	tables := []string{"log", "statehist"}
+	if strings.Contains(cmd, "HOST") || strings.Contains(cmd, "SVC") || strings.Contains(cmd, "SERVICE") {
+		// Hosts and services report the state of each other, and the
+		// status table counts them
+		tables = append(tables, "hosts", "services", "hostgroups", "servicegroups", "status")
+	}
+	if strings.Contains(cmd, "COMMENT") || strings.Contains(cmd, "ACKNOWLEDGE") {
+		tables = append(tables, "comments")
+	}
+	if strings.Contains(cmd, "DOWNTIME") {
+		tables = append(tables, "downtimes")
+	}
+	if strings.Contains(cmd, "CONTACT") {
+		tables = append(tables, "contacts", "contactgroups")
+	}
+	return tables
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,280 @@
+package livestatus
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var cacheFixtures = map[string]string{
+	"hosts":     `[["name"],["db1"]]`,
+	"services":  `[["description"],["Disk"]]`,
+	"downtimes": `[["id"],[1]]`,
+	"status":    `[["program_start"],[1500000000]]`,
+}
+
+func Test_CacheHit(t *testing.T) {
+	ls, s := newFakeLivestatus(fixtureHandler(cacheFixtures))
+	ls.SetCache(NewCache(time.Minute))
+
+	for i := 0; i < 3; i++ {
+		resp, err := ls.Query("hosts").Exec()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Record{Record{"name": "db1"}}
+		if !reflect.DeepEqual(resp.Records, expected) {
+			t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Records)
+			t.Fail()
+		}
+	}
+
+	// A different request is not served from the cache
+	if _, err := ls.Query("hosts").Columns("name").Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(s.Queries()); n != 2 {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", 2, n)
+		t.Fail()
+	}
+}
+
+func Test_CacheTTL(t *testing.T) {
+	ls, s := newFakeLivestatus(fixtureHandler(cacheFixtures))
+	c := NewCache(time.Minute).SetTTL("services", 0).SetTTL("downtimes", 10*time.Second)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+	ls.SetCache(c)
+
+	run := func(tables ...string) {
+		for _, table := range tables {
+			if _, err := ls.Query(table).Exec(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	run("hosts", "services", "downtimes", "hosts", "services", "downtimes")
+	now = now.Add(30 * time.Second)
+	run("hosts", "services", "downtimes")
+
+	var result []string
+	for _, q := range s.Queries() {
+		result = append(result, q[:len("GET ")+5])
+	}
+	expected := []string{
+		"GET hosts", "GET servi", "GET downt", "GET servi", "GET servi", "GET downt",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_CacheInvalidate(t *testing.T) {
+	ls, s := newFakeLivestatus(fixtureHandler(cacheFixtures))
+	ls.SetCache(NewCache(time.Minute))
+
+	run := func(tables ...string) {
+		for _, table := range tables {
+			if _, err := ls.Query(table).Exec(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	run("hosts", "downtimes")
+
+	// Host commands leave downtimes untouched
+	c := ls.Command()
+	c.Raw("DISABLE_HOST_CHECK")
+	c.Arg("db1")
+	if _, err := c.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	ls.Close()
+	run("hosts", "downtimes")
+
+	// Global commands may change any table
+	c = ls.Command()
+	c.Raw("DISABLE_NOTIFICATIONS")
+	if _, err := c.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	ls.Close()
+	run("hosts", "downtimes")
+
+	if n := len(s.Queries()); n != 5 {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", 5, n)
+		t.Fail()
+	}
+
+	// Global toggles naming hosts or services change the status table
+	run("status")
+	c = ls.Command()
+	c.Raw("ENABLE_HOST_FRESHNESS_CHECKS")
+	if _, err := c.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	ls.Close()
+	run("status")
+
+	if n := len(s.Queries()); n != 7 {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", 7, n)
+		t.Fail()
+	}
+}
+
+func Test_CommandTables(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		args     []string
+		expected []string
+	}{
+		{"DISABLE_NOTIFICATIONS", nil, nil},
+		{"STOP_EXECUTING_SVC_CHECKS", nil, nil},
+		{"DISABLE_HOST_NOTIFICATIONS", []string{"db1"}, []string{"log", "statehist", "hosts", "services", "hostgroups", "servicegroups", "status"}},
+		{"DEL_HOST_DOWNTIME", []string{"12"}, []string{"log", "statehist", "hosts", "services", "hostgroups", "servicegroups", "status", "downtimes"}},
+	}
+
+	for _, tt := range tests {
+		if result := commandTables(tt.cmd, tt.args); !reflect.DeepEqual(result, tt.expected) {
+			t.Logf("\nExpected %#v for %s\nbut got  %#v\n", tt.expected, tt.cmd, result)
+			t.Fail()
+		}
+	}
+}
+
+// waitShared blocks until n queries have joined the query in flight.
+func waitShared(c *Cache, n int) {
+	for {
+		c.mu.Lock()
+		waiters := 0
+		for _, call := range c.calls {
+			waiters += call.waiters
+		}
+		c.mu.Unlock()
+		if waiters >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func Test_CacheSharedQuery(t *testing.T) {
+	handler := fixtureHandler(cacheFixtures)
+
+	c := NewCache(time.Minute)
+	var servers []*fakeServer
+	var lss []*Livestatus
+	for i := 0; i < 5; i++ {
+		ls, s := newFakeLivestatus(func(req string) (int, string) {
+			// Let every other query join this one before answering it
+			waitShared(c, 4)
+			return handler(req)
+		})
+		ls.SetCache(c)
+		lss = append(lss, ls)
+		servers = append(servers, s)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(lss))
+	for i, ls := range lss {
+		wg.Add(1)
+		go func(i int, ls *Livestatus) {
+			defer wg.Done()
+			_, errs[i] = ls.Query("hosts").Exec()
+		}(i, ls)
+	}
+	wg.Wait()
+
+	queries := 0
+	for i, s := range servers {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		queries += len(s.Queries())
+	}
+	if queries != 1 {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", 1, queries)
+		t.Fail()
+	}
+}
+
+func Test_CacheSharedQueryCancel(t *testing.T) {
+	handler := fixtureHandler(cacheFixtures)
+	block := make(chan struct{})
+	defer close(block)
+
+	c := NewCache(time.Minute)
+	first, _ := newFakeLivestatus(func(req string) (int, string) {
+		<-block
+		return handler(req)
+	})
+	first.SetCache(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := first.Query("hosts").ExecContext(ctx)
+		firstErr <- err
+	}()
+
+	// Wait for the first query to be in flight
+	for {
+		c.mu.Lock()
+		n := len(c.calls)
+		c.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		ls, _ := newFakeLivestatus(handler)
+		ls.SetCache(c)
+		wg.Add(1)
+		go func(i int, ls *Livestatus) {
+			defer wg.Done()
+			_, errs[i] = ls.Query("hosts").Exec()
+		}(i, ls)
+	}
+
+	waitShared(c, len(errs))
+	cancel()
+	wg.Wait()
+
+	if err := <-firstErr; err != context.Canceled {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", context.Canceled, err)
+		t.Fail()
+	}
+	for _, err := range errs {
+		if err != nil {
+			t.Logf("\nExpected %#v\nbut got  %#v\n", nil, err)
+			t.Fail()
+		}
+	}
+}
+
+func Test_CacheWaitTrigger(t *testing.T) {
+	ls, s := newFakeLivestatus(fixtureHandler(cacheFixtures))
+	ls.SetCache(NewCache(time.Minute))
+
+	for i := 0; i < 2; i++ {
+		if _, err := ls.Query("hosts").WaitTrigger("check").Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := len(s.Queries()); n != 2 {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", 2, n)
+		t.Fail()
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+// colType describes how the values of a column type are represented in the
+// generated code.
+type colType struct {
+	goType string
+	getter string
+	// filters are the helpers generated for columns of the type, as a
+	// suffix, the argument type, the filter operator and the formatting of
+	// the argument as filter value
+	filters []filterHelper
+}
+
+type filterHelper struct {
+	Suffix, ArgType, Op, Value string
+}
+
+var (
+	numberFilters = []filterHelper{
+		{"Eq", "", "=", "%v"},
+		{"Lt", "", "<", "%v"},
+		{"Gt", "", ">", "%v"},
+	}
+
+	colTypes = map[string]colType{
+		"int":   {"int64", "GetInt", numberFilters},
+		"float": {"float64", "GetFloat", numberFilters},
+		"string": {"string", "GetString", []filterHelper{
+			{"Eq", "string", "=", "%s"},
+			{"Matches", "string", "~", "%s"},
+		}},
+		"time": {"time.Time", "GetTime", []filterHelper{
+			{"Before", "time.Time", "<", "%d"},
+			{"After", "time.Time", ">", "%d"},
+		}},
+		"list": {"[]interface{}", "GetSlice", []filterHelper{
+			{"Contains", "string", ">=", "%s"},
+		}},
+		"dict": {"map[string]string", "GetStringMap", nil},
+	}
+
+	initialisms = map[string]string{
+		"id": "ID", "ip": "IP", "url": "URL", "uri": "URI", "json": "JSON", "acl": "ACL",
+	}
+)
+
+type genTable struct {
+	Name    string
+	GoName  string
+	Columns []genColumn
+}
+
+type genColumn struct {
+	Name        string
+	Field       string
+	Const       string
+	Type        string
+	Getter      string
+	Description string
+	Filters     []genFilter
+}
+
+type genFilter struct {
+	Func, ArgType, Op, Value string
+}
+
+// genCode writes the code for the given tables of a schema, or all of them
+// when none are given.
+func genCode(w io.Writer, pkg string, schema *lvst.Schema, names []string) error {
+	if len(names) == 0 {
+		names = schema.Tables()
+	}
+
+	var tables []genTable
+	needTime, needFmt := false, false
+	for _, name := range names {
+		cols := schema.Columns(name)
+		if len(cols) == 0 {
+			return fmt.Errorf("no such table %s", name)
+		}
+
+		t := genTable{Name: name, GoName: goName(name)}
+		reserved := map[string]bool{
+			t.GoName + "Record":  true,
+			t.GoName + "Columns": true,
+		}
+		fields := map[string]bool{}
+
+		for _, c := range cols {
+			ct, ok := colTypes[c.Type]
+			if !ok {
+				// Unknown types are left to the generic record getters
+				continue
+			}
+			needTime = needTime || c.Type == "time"
+
+			gc := genColumn{
+				Name:        c.Name,
+				Field:       goName(c.Name),
+				Type:        ct.goType,
+				Getter:      ct.getter,
+				Description: strings.Join(strings.Fields(c.Description), " "),
+			}
+			for fields[gc.Field] {
+				gc.Field += "_"
+			}
+			fields[gc.Field] = true
+
+			gc.Const = t.GoName + gc.Field
+			if reserved[gc.Const] {
+				gc.Const += "Column"
+			}
+			reserved[gc.Const] = true
+
+			for _, f := range ct.filters {
+				argType := f.ArgType
+				if argType == "" {
+					argType = ct.goType
+				}
+				value := fmt.Sprintf("fmt.Sprintf(%q, v)", f.Value)
+				switch {
+				case argType == "time.Time":
+					value = fmt.Sprintf("fmt.Sprintf(%q, v.Unix())", f.Value)
+				case argType == "string":
+					value = "v"
+				}
+				gc.Filters = append(gc.Filters, genFilter{
+					Func:    gc.Const + f.Suffix,
+					ArgType: argType,
+					Op:      f.Op,
+					Value:   value,
+				})
+			}
+
+			t.Columns = append(t.Columns, gc)
+			needFmt = true
+		}
+
+		if len(t.Columns) > 0 {
+			tables = append(tables, t)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+
+	buf := bytes.NewBuffer(nil)
+	err := codeTemplate.Execute(buf, map[string]interface{}{
+		"Package":  pkg,
+		"Tables":   tables,
+		"NeedTime": needTime,
+		"NeedFmt":  needFmt,
+	})
+	if err != nil {
+		return err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("invalid generated code, %v", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// goName returns the exported Go name of a table or column name, such as
+// LastCheck for last_check.
+func goName(s string) string {
+	var b strings.Builder
+	for _, p := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if v, ok := initialisms[p]; ok {
+			b.WriteString(v)
+			continue
+		}
+		b.WriteString(strings.ToUpper(p[:1]) + p[1:])
+	}
+
+	name := b.String()
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		name = "X" + name
+	}
+	return name
+}
+
+var codeTemplate = template.Must(template.New("code").Parse(`// Code generated by gen-livestatus-tables. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- if .NeedFmt}}
+	"fmt"
+{{- end}}
+{{- if .NeedTime}}
+	"time"
+{{- end}}
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+{{range $t := .Tables}}
+// Columns of the {{$t.Name}} table.
+const (
+{{- range $t.Columns}}
+	{{.Const}} = {{printf "%q" .Name}}
+{{- end}}
+)
+
+// {{$t.GoName}}Columns are the names of the columns of the {{$t.Name}} table.
+var {{$t.GoName}}Columns = []string{
+{{- range $t.Columns}}
+	{{.Const}},
+{{- end}}
+}
+
+// {{$t.GoName}}Record is a record of the {{$t.Name}} table.
+type {{$t.GoName}}Record struct {
+{{- range $t.Columns}}
+	{{- if .Description}}
+	// {{.Description}}
+	{{- end}}
+	{{.Field}} {{.Type}}
+{{- end}}
+}
+
+// Decode{{$t.GoName}}Record decodes a record of the {{$t.Name}} table. Fields of
+// the columns missing from the record are left unset.
+func Decode{{$t.GoName}}Record(r lvst.Record) ({{$t.GoName}}Record, error) {
+	var res {{$t.GoName}}Record
+	var err error
+{{- range $t.Columns}}
+	if _, ok := r[{{.Const}}]; ok {
+		if res.{{.Field}}, err = r.{{.Getter}}({{.Const}}); err != nil {
+			return res, fmt.Errorf("column %s, %v", {{.Const}}, err)
+		}
+	}
+{{- end}}
+	return res, nil
+}
+{{range $c := $t.Columns}}{{range .Filters}}
+// {{.Func}} filters on the {{$c.Name}} column with the {{.Op}} operator.
+func {{.Func}}(v {{.ArgType}}) lvst.FilterRule {
+	return lvst.FilterRule{Column: {{$c.Const}}, Op: {{printf "%q" .Op}}, Value: {{.Value}}}
+}
+{{end}}{{end}}{{end}}`))
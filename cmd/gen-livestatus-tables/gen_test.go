@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+func Test_GenCode(t *testing.T) {
+	f, err := os.Open("testdata/columns.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	schema, err := lvst.ReadSchema(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := genCode(buf, "tables", schema, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := ioutil.ReadFile("testdata/tables.go.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Logf("\nExpected %s\nbut got  %s\n", expected, buf.Bytes())
+		t.Fail()
+	}
+
+	if err := genCode(buf, "tables", schema, []string{"nosuchtable"}); err == nil {
+		t.Logf("\nExpected an error for an unknown table\n")
+		t.Fail()
+	}
+}
+
+func Test_GoName(t *testing.T) {
+	tests := map[string]string{
+		"name":                "Name",
+		"last_check":          "LastCheck",
+		"comments_with_info":  "CommentsWithInfo",
+		"id":                  "ID",
+		"action_url_expanded": "ActionURLExpanded",
+		"servicesbyhostgroup": "Servicesbyhostgroup",
+		"4xx_count":           "X4xxCount",
+	}
+
+	for in, expected := range tests {
+		if result := goName(in); result != expected {
+			t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Command gen-livestatus-tables generates Go types for the tables of a
+// Livestatus instance, from a JSON dump of its columns table such as written
+// by
+//
+//	lq -format json columns > columns.json
+//
+// so that the generated code matches the monitoring core in use. For each
+// table, it generates a record struct, a decoding function, column name
+// constants and typed filter helpers. The schema may also be read from a
+// live instance with -address.
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+var (
+	in      = flag.String("i", "", "columns table JSON dump to read, - for stdin")
+	out     = flag.String("o", "", "output file to write to")
+	pkg     = flag.String("pkg", "tables", "package name of the generated code")
+	tables  = flag.String("tables", "", "comma separated tables to generate, all by default")
+	network = flag.String("network", "unix", "network of the livestatus socket to read the schema from, unix or tcp")
+	address = flag.String("address", "", "address of the livestatus socket to read the schema from, instead of a dump")
+)
+
+func main() {
+	flag.Parse()
+
+	schema, err := readSchema()
+	if err != nil {
+		log.Fatalf("error reading schema, %v", err)
+	}
+
+	var names []string
+	if *tables != "" {
+		names = strings.Split(*tables, ",")
+	}
+
+	var file io.WriteCloser = os.Stdout
+	if *out != "" {
+		file, err = os.Create(*out)
+		if err != nil {
+			log.Fatalf("error creating file, %v", err)
+		}
+	}
+	defer file.Close()
+
+	if err := genCode(file, *pkg, schema, names); err != nil {
+		log.Fatalf("error generating code, %v", err)
+	}
+}
+
+func readSchema() (*lvst.Schema, error) {
+	switch {
+	case *address != "":
+		ls := lvst.NewLivestatus(*network, *address)
+		defer ls.Close()
+		return lvst.LoadSchema(context.Background(), ls)
+	case *in == "" || *in == "-":
+		return lvst.ReadSchema(os.Stdin)
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return lvst.ReadSchema(f)
+}
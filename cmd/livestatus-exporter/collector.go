@@ -0,0 +1,291 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+const namespace = "nagios"
+
+var errInvalidLabelMap = errors.New("label mappings must be of the form VAR=label")
+
+var (
+	hostColumns = []string{
+		"name", "state", "latency", "execution_time", "perf_data", "custom_variables",
+	}
+	serviceColumns = []string{
+		"host_name", "description", "state", "latency", "execution_time", "perf_data", "custom_variables",
+	}
+	statusColumns = []string{
+		"program_start", "program_version", "livestatus_version",
+		"host_checks_rate", "service_checks_rate", "num_hosts", "num_services",
+	}
+)
+
+// collector queries livestatus on each scrape and reports the state of the
+// monitored hosts and services.
+type collector struct {
+	sync.Mutex
+	ls *lvst.Livestatus
+
+	vars   []string
+	labels []string
+
+	up                *prometheus.Desc
+	hostState         *prometheus.Desc
+	hostLatency       *prometheus.Desc
+	hostExecTime      *prometheus.Desc
+	hostPerfData      *prometheus.Desc
+	serviceState      *prometheus.Desc
+	serviceLatency    *prometheus.Desc
+	serviceExecTime   *prometheus.Desc
+	servicePerfData   *prometheus.Desc
+	programInfo       *prometheus.Desc
+	programStart      *prometheus.Desc
+	hostChecksRate    *prometheus.Desc
+	serviceChecksRate *prometheus.Desc
+	numHosts          *prometheus.Desc
+	numServices       *prometheus.Desc
+}
+
+func newCollector(ls *lvst.Livestatus, lm labelMap) *collector {
+	c := &collector{ls: ls}
+
+	for v := range lm {
+		c.vars = append(c.vars, v)
+	}
+	sort.Strings(c.vars)
+	for _, v := range c.vars {
+		c.labels = append(c.labels, lm[v])
+	}
+
+	hostLabels := append([]string{"host_name"}, c.labels...)
+	serviceLabels := append([]string{"host_name", "service_description"}, c.labels...)
+	perfLabels := []string{"label", "unit"}
+
+	c.up = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "up"),
+		"Whether the last query of livestatus was successful",
+		nil, nil)
+	c.hostState = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "host", "state"),
+		"Current state of the host, 0 up, 1 down, 2 unreachable",
+		hostLabels, nil)
+	c.hostLatency = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "host", "check_latency_seconds"),
+		"Latency of the last host check",
+		hostLabels, nil)
+	c.hostExecTime = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "host", "check_execution_time_seconds"),
+		"Execution time of the last host check",
+		hostLabels, nil)
+	c.hostPerfData = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "host", "perfdata"),
+		"Performance data reported by the last host check",
+		append(hostLabels, perfLabels...), nil)
+	c.serviceState = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "service", "state"),
+		"Current state of the service, 0 ok, 1 warning, 2 critical, 3 unknown",
+		serviceLabels, nil)
+	c.serviceLatency = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "service", "check_latency_seconds"),
+		"Latency of the last service check",
+		serviceLabels, nil)
+	c.serviceExecTime = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "service", "check_execution_time_seconds"),
+		"Execution time of the last service check",
+		serviceLabels, nil)
+	c.servicePerfData = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "service", "perfdata"),
+		"Performance data reported by the last service check",
+		append(serviceLabels, perfLabels...), nil)
+	c.programInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "program", "info"),
+		"Version information of the monitoring core",
+		[]string{"program_version", "livestatus_version"}, nil)
+	c.programStart = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "program", "start_time_seconds"),
+		"Time the monitoring core was started, in seconds since the epoch",
+		nil, nil)
+	c.hostChecksRate = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "program", "host_checks_rate"),
+		"Rate of host checks per second",
+		nil, nil)
+	c.serviceChecksRate = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "program", "service_checks_rate"),
+		"Rate of service checks per second",
+		nil, nil)
+	c.numHosts = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "program", "hosts"),
+		"Number of hosts being monitored",
+		nil, nil)
+	c.numServices = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "program", "services"),
+		"Number of services being monitored",
+		nil, nil)
+
+	return c
+}
+
+// Describe implements prometheus.Collector.
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.hostState
+	ch <- c.hostLatency
+	ch <- c.hostExecTime
+	ch <- c.hostPerfData
+	ch <- c.serviceState
+	ch <- c.serviceLatency
+	ch <- c.serviceExecTime
+	ch <- c.servicePerfData
+	ch <- c.programInfo
+	ch <- c.programStart
+	ch <- c.hostChecksRate
+	ch <- c.serviceChecksRate
+	ch <- c.numHosts
+	ch <- c.numServices
+}
+
+// Collect implements prometheus.Collector.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	// The Livestatus instance tracks connection state, so scrapes must not
+	// query it concurrently.
+	c.Lock()
+	defer c.Unlock()
+
+	up := 1.0
+	for _, f := range []func(chan<- prometheus.Metric) error{
+		c.collectStatus,
+		c.collectHosts,
+		c.collectServices,
+	} {
+		if err := f(ch); err != nil {
+			log.Printf("error querying livestatus, %v", err)
+			up = 0
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, up)
+}
+
+func (c *collector) collectStatus(ch chan<- prometheus.Metric) error {
+	rs, err := c.query("status", statusColumns)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rs {
+		pv, _ := r.GetString("program_version")
+		lv, _ := r.GetString("livestatus_version")
+		ch <- prometheus.MustNewConstMetric(c.programInfo, prometheus.GaugeValue, 1, pv, lv)
+
+		c.gauge(ch, c.programStart, r, "program_start")
+		c.gauge(ch, c.hostChecksRate, r, "host_checks_rate")
+		c.gauge(ch, c.serviceChecksRate, r, "service_checks_rate")
+		c.gauge(ch, c.numHosts, r, "num_hosts")
+		c.gauge(ch, c.numServices, r, "num_services")
+	}
+
+	return nil
+}
+
+func (c *collector) collectHosts(ch chan<- prometheus.Metric) error {
+	rs, err := c.query("hosts", hostColumns)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rs {
+		name, err := r.GetString("name")
+		if err != nil {
+			continue
+		}
+		lvs := append([]string{name}, c.labelValues(r)...)
+
+		c.gauge(ch, c.hostState, r, "state", lvs...)
+		c.gauge(ch, c.hostLatency, r, "latency", lvs...)
+		c.gauge(ch, c.hostExecTime, r, "execution_time", lvs...)
+		c.perfData(ch, c.hostPerfData, r, lvs)
+	}
+
+	return nil
+}
+
+func (c *collector) collectServices(ch chan<- prometheus.Metric) error {
+	rs, err := c.query("services", serviceColumns)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rs {
+		host, err := r.GetString("host_name")
+		if err != nil {
+			continue
+		}
+		desc, err := r.GetString("description")
+		if err != nil {
+			continue
+		}
+		lvs := append([]string{host, desc}, c.labelValues(r)...)
+
+		c.gauge(ch, c.serviceState, r, "state", lvs...)
+		c.gauge(ch, c.serviceLatency, r, "latency", lvs...)
+		c.gauge(ch, c.serviceExecTime, r, "execution_time", lvs...)
+		c.perfData(ch, c.servicePerfData, r, lvs)
+	}
+
+	return nil
+}
+
+func (c *collector) query(table string, cols []string) ([]lvst.Record, error) {
+	resp, err := c.ls.Query(table).Columns(cols...).Exec()
+	if err != nil {
+		return nil, err
+	}
+	return resp.Records, nil
+}
+
+// labelValues returns the values of the mapped custom variables, in label
+// order. Missing variables are reported as empty labels.
+func (c *collector) labelValues(r lvst.Record) []string {
+	lvs := make([]string, len(c.vars))
+	vars, err := r.GetStringMap("custom_variables")
+	if err != nil {
+		return lvs
+	}
+	for i, v := range c.vars {
+		lvs[i] = vars[v]
+	}
+	return lvs
+}
+
+func (c *collector) gauge(ch chan<- prometheus.Metric, d *prometheus.Desc, r lvst.Record, col string, lvs ...string) {
+	v, err := r.GetFloat(col)
+	if err != nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, lvs...)
+}
+
+func (c *collector) perfData(ch chan<- prometheus.Metric, d *prometheus.Desc, r lvst.Record, lvs []string) {
+	pds, err := r.GetPerfData("perf_data")
+	if err != nil {
+		return
+	}
+	seen := map[string]bool{}
+	for _, pd := range pds {
+		// Duplicate labels would produce an invalid exposition
+		if math.IsNaN(pd.Value) || seen[pd.Label] {
+			continue
+		}
+		seen[pd.Label] = true
+		plvs := append(append([]string{}, lvs...), pd.Label, pd.Unit)
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, pd.Value, plvs...)
+	}
+}
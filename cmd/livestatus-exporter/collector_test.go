@@ -0,0 +1,136 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/tcolgate/go-livestatus/internal/livestatustest"
+)
+
+var fixtures = map[string]string{
+	"status": `[[1463391510,"2.4.0","1.2.8p20",1.5,12.25,2,3]]`,
+	"hosts": `[
+		["db1",0,0.25,0.5,"rta=0.5ms;100;500;0",{"OWNER":"dba"}],
+		["web1",1,0.1,4,"",{}]
+	]`,
+	"services": `[
+		["db1","Disk",2,0.5,1.25,"'/var'=95%;80;90;0;100 inodes=U",{"OWNER":"dba"}],
+		["db1","Load",0,0.5,0.25,"load1=0.1 load1=0.2",{}],
+		["web1","HTTP",0,0.5,0.75,"time=0.1s",{"OWNER":"web"}]
+	]`,
+}
+
+func gather(t *testing.T, c prometheus.Collector) map[string][]*dto.Metric {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := map[string][]*dto.Metric{}
+	for _, mf := range mfs {
+		res[mf.GetName()] = mf.GetMetric()
+	}
+	return res
+}
+
+func findMetric(ms []*dto.Metric, labels map[string]string) *dto.Metric {
+	for _, m := range ms {
+		match := true
+		for _, lp := range m.GetLabel() {
+			if v, ok := labels[lp.GetName()]; ok && v != lp.GetValue() {
+				match = false
+			}
+		}
+		if match {
+			return m
+		}
+	}
+	return nil
+}
+
+func Test_Collector(t *testing.T) {
+	ls := livestatustest.NewLivestatus(fixtures)
+
+	ms := gather(t, newCollector(ls, labelMap{"OWNER": "owner"}))
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		value  float64
+	}{
+		{"nagios_up", nil, 1},
+		{"nagios_program_start_time_seconds", nil, 1463391510},
+		{"nagios_program_services", nil, 3},
+		{"nagios_program_info", map[string]string{"program_version": "2.4.0"}, 1},
+		{"nagios_host_state", map[string]string{"host_name": "web1", "owner": ""}, 1},
+		{"nagios_host_check_latency_seconds", map[string]string{"host_name": "db1", "owner": "dba"}, 0.25},
+		{"nagios_host_perfdata", map[string]string{"host_name": "db1", "label": "rta", "unit": "ms"}, 0.5},
+		{"nagios_service_state", map[string]string{"service_description": "Disk", "owner": "dba"}, 2},
+		{"nagios_service_check_execution_time_seconds", map[string]string{"service_description": "HTTP", "owner": "web"}, 0.75},
+		{"nagios_service_perfdata", map[string]string{"service_description": "Disk", "label": "/var", "unit": "%"}, 95},
+		{"nagios_service_perfdata", map[string]string{"service_description": "Load", "label": "load1"}, 0.1},
+	}
+
+	for _, tt := range tests {
+		m := findMetric(ms[tt.name], tt.labels)
+		if m == nil {
+			t.Logf("\nExpected metric %s%v\nbut got none\n", tt.name, tt.labels)
+			t.Fail()
+			continue
+		}
+		if v := m.GetGauge().GetValue(); v != tt.value {
+			t.Logf("\nExpected %s%v = %v\nbut got  %v\n", tt.name, tt.labels, tt.value, v)
+			t.Fail()
+		}
+	}
+
+	// Unknown values and repeated labels are dropped
+	if n := len(ms["nagios_service_perfdata"]); n != 3 {
+		t.Logf("\nExpected 3 service perfdata metrics\nbut got  %d\n", n)
+		t.Fail()
+	}
+}
+
+func Test_CollectorDown(t *testing.T) {
+	ls := livestatustest.NewLivestatus(nil)
+
+	ms := gather(t, newCollector(ls, labelMap{}))
+
+	m := findMetric(ms["nagios_up"], nil)
+	if m == nil || m.GetGauge().GetValue() != 0 {
+		t.Logf("\nExpected nagios_up 0\nbut got  %v\n", m)
+		t.Fail()
+	}
+}
+
+func Test_LabelMapSet(t *testing.T) {
+	lm := labelMap{}
+	if err := lm.Set("owner=owner,Tier=tier"); err != nil {
+		t.Fatal(err)
+	}
+	if lm["OWNER"] != "owner" || lm["TIER"] != "tier" {
+		t.Logf("\nUnexpected label map %#v\n", lm)
+		t.Fail()
+	}
+	if err := lm.Set("broken"); err != errInvalidLabelMap {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", errInvalidLabelMap, err)
+		t.Fail()
+	}
+
+	for _, s := range []string{
+		"site=bad-label", "site=host_name", "site=unit", "site=__name", "team=owner",
+	} {
+		if err := lm.Set(s); err == nil {
+			t.Logf("\nExpected an error setting %q\n", s)
+			t.Fail()
+		}
+	}
+	if err := lm.Set("owner=team"); err != nil || lm["OWNER"] != "team" {
+		t.Logf("\nExpected OWNER to be remapped\nbut got  %#v, %#v\n", err, lm)
+		t.Fail()
+	}
+}
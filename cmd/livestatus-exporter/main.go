@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/model"
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+var (
+	listen  = flag.String("listen", ":9142", "address to serve metrics on")
+	network = flag.String("network", "unix", "network of the livestatus socket, unix or tcp")
+	address = flag.String("address", "/var/run/nagios/livestatus.sock", "address of the livestatus socket")
+	path    = flag.String("path", "/metrics", "path to serve metrics on")
+	labels  = labelMap{}
+)
+
+// labelMap maps custom variable names onto prometheus label names.
+type labelMap map[string]string
+
+func (m labelMap) String() string {
+	var strs []string
+	for k, v := range m {
+		strs = append(strs, k+"="+v)
+	}
+	return strings.Join(strs, ",")
+}
+
+// reservedLabels are the label names set by the collector itself.
+var reservedLabels = map[string]bool{
+	"host_name": true, "service_description": true, "label": true, "unit": true,
+}
+
+func (m labelMap) Set(s string) error {
+	for _, kv := range strings.Split(s, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return errInvalidLabelMap
+		}
+		v, l := strings.ToUpper(parts[0]), parts[1]
+
+		// Invalid or duplicate label names would panic when scraping
+		if !model.LabelName(l).IsValid() || strings.HasPrefix(l, "__") {
+			return fmt.Errorf("invalid label name %q", l)
+		}
+		if reservedLabels[l] {
+			return fmt.Errorf("label %s is reserved", l)
+		}
+		for ov, ol := range m {
+			if ol == l && ov != v {
+				return fmt.Errorf("label %s is already mapped from %s", l, ov)
+			}
+		}
+		m[v] = l
+	}
+	return nil
+}
+
+func main() {
+	flag.Var(labels, "label", "map a custom variable onto a label, as VAR=label, may be repeated")
+	flag.Parse()
+
+	ls := lvst.NewLivestatus(*network, *address)
+	prometheus.MustRegister(newCollector(ls, labels))
+
+	http.Handle(*path, promhttp.Handler())
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+var (
+	network     = flag.String("network", "tcp", "network to listen on, unix or tcp")
+	address     = flag.String("address", ":6557", "address to listen on")
+	connections = flag.Int("connections", 4, "number of connections kept open to each site")
+	timeout     = flag.Duration("timeout", defaultTimeout, "timeout of the requests to the sites")
+	sites       = siteList{}
+)
+
+var errInvalidSite = errors.New("sites must be given as name=network:address")
+
+// siteList is a flag listing the backend sites.
+type siteList []siteAddr
+
+type siteAddr struct {
+	name, network, address string
+}
+
+func (l *siteList) String() string {
+	var strs []string
+	for _, s := range *l {
+		strs = append(strs, s.name+"="+s.network+":"+s.address)
+	}
+	return strings.Join(strs, ",")
+}
+
+func (l *siteList) Set(s string) error {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return errInvalidSite
+	}
+	addr := strings.SplitN(parts[1], ":", 2)
+	if len(addr) != 2 || (addr[0] != "unix" && addr[0] != "tcp") || addr[1] == "" {
+		return errInvalidSite
+	}
+	*l = append(*l, siteAddr{parts[0], addr[0], addr[1]})
+	return nil
+}
+
+func main() {
+	flag.Var(&sites, "site", "backend site, as name=unix:/path/to/socket or name=tcp:host:port, may be repeated")
+	flag.Parse()
+
+	if len(sites) == 0 {
+		log.Fatal("at least one site is required")
+	}
+
+	p := &proxy{timeout: *timeout}
+	for _, sa := range sites {
+		sa := sa
+		// Skip sites failing to answer, as the pooled instances share a breaker
+		b := lvst.NewBreaker(sa.name)
+		p.sites = append(p.sites, newSite(sa.name, *connections, func() *lvst.Livestatus {
+			ls := lvst.NewLivestatus(sa.network, sa.address)
+			ls.SetBreaker(b)
+			return ls
+		}))
+	}
+
+	if *network == "unix" {
+		// Remove any socket left over by a previous run
+		os.Remove(*address)
+	}
+	l, err := net.Listen(*network, *address)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go p.serveConn(conn)
+	}
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	lvst "github.com/tcolgate/go-livestatus"
+	"github.com/tcolgate/go-livestatus/nagios"
+	"github.com/tcolgate/go-livestatus/server"
+)
+
+// siteColumn is the column added to every record, holding the name of the
+// site it comes from.
+const siteColumn = "site"
+
+// defaultTimeout is the default timeout of the requests to the sites.
+const defaultTimeout = 30 * time.Second
+
+// site is a backend Livestatus site, queried through a pool of connections
+// kept alive between queries.
+type site struct {
+	name string
+	pool chan *lvst.Livestatus
+}
+
+func newSite(name string, size int, newLivestatus func() *lvst.Livestatus) *site {
+	s := &site{name: name, pool: make(chan *lvst.Livestatus, size)}
+	for i := 0; i < size; i++ {
+		s.pool <- newLivestatus()
+	}
+	return s
+}
+
+// do runs f with a pooled Livestatus instance, dropping its connection if f
+// fails.
+func (s *site) do(ctx context.Context, f func(ls *lvst.Livestatus) error) error {
+	var ls *lvst.Livestatus
+	select {
+	case ls = <-s.pool:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { s.pool <- ls }()
+
+	err := f(ls)
+	if err != nil {
+		ls.Close()
+	}
+	return err
+}
+
+// query executes a query on the site. Queries failing on a pooled
+// connection are retried once on a new one, as the site may have closed it.
+func (s *site) query(ctx context.Context, table string, build func(q *lvst.Query)) (*lvst.Response, error) {
+	var resp *lvst.Response
+	err := s.do(ctx, func(ls *lvst.Livestatus) error {
+		var err error
+		for i := 0; i < 2; i++ {
+			q := ls.Query(table)
+			build(q)
+
+			if resp, err = q.KeepAlive().ExecContext(ctx); err == nil {
+				return nil
+			}
+			var serr *lvst.StatusError
+			if errors.As(err, &serr) || ctx.Err() != nil {
+				return err
+			}
+			ls.Close()
+		}
+		return err
+	})
+	return resp, err
+}
+
+func (s *site) command(ctx context.Context, name string, args []string) error {
+	return s.do(ctx, func(ls *lvst.Livestatus) error {
+		c := ls.Command()
+		c.Raw(name)
+		for _, a := range args {
+			c.Arg(a)
+		}
+		_, err := c.ExecContext(ctx)
+		return err
+	})
+}
+
+// proxy serves the Livestatus protocol, forwarding requests to its sites.
+type proxy struct {
+	sites   []*site
+	timeout time.Duration
+}
+
+func (p *proxy) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	rd := bufio.NewReader(conn)
+	for {
+		req, err := server.ReadRequest(rd)
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			server.WriteResponse(conn, &server.Request{ResponseHeader: "fixed16"}, server.RequestStatus(err), []byte(err.Error()+"\n"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		if req.Command != "" {
+			p.command(ctx, req.Command)
+			cancel()
+			continue
+		}
+
+		status, body := p.query(ctx, req)
+		cancel()
+		if err := server.WriteResponse(conn, req, status, body); err != nil || !req.KeepAlive {
+			return
+		}
+	}
+}
+
+// query forwards a query to every site, merging their results.
+func (p *proxy) query(ctx context.Context, req *server.Request) (int, []byte) {
+	for _, st := range req.Stats {
+		switch st.Op {
+		case "count", "sum", "suminv", "min", "max":
+		default:
+			return 452, []byte(fmt.Sprintf("Stats %s cannot be merged across sites\n", st.Op))
+		}
+	}
+
+	var cols []string
+	for _, c := range req.Columns {
+		if c != siteColumn {
+			cols = append(cols, c)
+		}
+	}
+
+	// Sites return a min or max of 0 when no record matches, count the
+	// records to tell those apart
+	countCol := ""
+	for _, st := range req.Stats {
+		if st.Op == "min" || st.Op == "max" {
+			countCol = st.Column
+			break
+		}
+	}
+
+	build := func(filter lvst.Filter) func(q *lvst.Query) {
+		return func(q *lvst.Query) {
+			if len(cols) > 0 {
+				q.Columns(cols...)
+			}
+			if filter != nil {
+				q.Where(filter)
+			}
+			for _, st := range req.Stats {
+				if st.Op == "count" {
+					q.StatsWhere(st.Filter)
+				} else {
+					q.Stats(st.Op + " " + st.Column)
+				}
+			}
+			if countCol != "" {
+				q.StatsWhere(lvst.FilterOr{
+					lvst.FilterRule{Column: countCol, Op: "=", Value: "0"},
+					lvst.FilterRule{Column: countCol, Op: "!=", Value: "0"},
+				})
+			}
+			if req.Limit > 0 {
+				q.Limit(req.Limit)
+			}
+			if req.AuthUser != "" {
+				q.AuthUser(req.AuthUser)
+			}
+		}
+	}
+
+	results := make([][]lvst.Record, len(p.sites))
+	columns := make([][]string, len(p.sites))
+	errs := make([]error, len(p.sites))
+
+	// Sites excluded by the filter answer with no record
+	available := 0
+
+	var wg sync.WaitGroup
+	for i, s := range p.sites {
+		// Sites have no site column, rules on it are evaluated here
+		filter, decided, match := reduceSiteFilter(req.Filter, s.name)
+		if decided && !match {
+			available++
+			continue
+		}
+		if decided {
+			filter = nil
+		}
+
+		wg.Add(1)
+		go func(i int, s *site) {
+			defer wg.Done()
+			resp, err := s.query(ctx, req.Table, build(filter))
+			if err == nil {
+				results[i], columns[i] = resp.Records, resp.Columns
+			}
+			errs[i] = err
+		}(i, s)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		var serr *lvst.StatusError
+		if errors.As(err, &serr) {
+			// The request itself is invalid, and would be on any site
+			return serr.Status, []byte(serr.Message + "\n")
+		} else if err != nil {
+			log.Printf("error querying site %s, %v", p.sites[i].name, err)
+			continue
+		}
+		available++
+	}
+	if available == 0 {
+		return 502, []byte("No site available\n")
+	}
+
+	outCols := req.Columns
+	if len(outCols) == 0 && len(req.Stats) == 0 {
+		// Keep the column order of the table, as given by the sites
+		for _, cols := range columns {
+			if len(cols) > 0 {
+				outCols = append(append([]string{}, cols...), siteColumn)
+				break
+			}
+		}
+	}
+
+	var rows [][]interface{}
+	if len(req.Stats) > 0 {
+		rows = p.mergeStats(req, results)
+	} else {
+		for i, records := range results {
+			for _, r := range records {
+				rows = append(rows, p.row(r, outCols, i))
+			}
+		}
+		if req.Limit > 0 && len(rows) > req.Limit {
+			rows = rows[:req.Limit]
+		}
+	}
+
+	body, err := server.EncodeRows(req, outCols, rows)
+	if err != nil {
+		return 500, []byte(err.Error() + "\n")
+	}
+	return 200, body
+}
+
+// row returns the values of the columns of a record from the i-th site.
+func (p *proxy) row(r lvst.Record, cols []string, i int) []interface{} {
+	row := make([]interface{}, len(cols))
+	for j, c := range cols {
+		if c == siteColumn {
+			row[j] = p.sites[i].name
+		} else {
+			row[j] = r[c]
+		}
+	}
+	return row
+}
+
+// mergeStats combines the stats returned by each site for the same group of
+// column values. The min and max of sites with no matching record, counted
+// by the stat following those requested, are ignored.
+func (p *proxy) mergeStats(req *server.Request, results [][]lvst.Record) [][]interface{} {
+	type group struct {
+		row     []interface{}
+		matched bool
+	}
+
+	var rows [][]interface{}
+	groups := map[string]*group{}
+
+	for i, records := range results {
+		for _, r := range records {
+			row := p.row(r, req.Columns, i)
+			key := fmt.Sprintf("%#v", row)
+
+			g, ok := groups[key]
+			if !ok {
+				for range req.Stats {
+					row = append(row, 0.0)
+				}
+				g = &group{row: row}
+				groups[key] = g
+				rows = append(rows, row)
+			}
+
+			n := 1.0
+			if v, ok := r[fmt.Sprintf("stats_%d", len(req.Stats)+1)].(float64); ok {
+				n = v
+			}
+
+			for j, st := range req.Stats {
+				cur, _ := g.row[len(req.Columns)+j].(float64)
+				v, _ := r[fmt.Sprintf("stats_%d", j+1)].(float64)
+				switch {
+				case st.Op != "min" && st.Op != "max":
+					cur += v
+				case n == 0:
+					continue
+				case !g.matched:
+					cur = v
+				case st.Op == "min":
+					cur = math.Min(cur, v)
+				default:
+					cur = math.Max(cur, v)
+				}
+				g.row[len(req.Columns)+j] = cur
+			}
+			g.matched = g.matched || n > 0
+		}
+	}
+
+	return rows
+}
+
+// reduceSiteFilter evaluates the rules of a filter on the site column for a
+// site. It returns the filter left to send to the site, or whether the
+// filter is decided and matches when no rule is left.
+func reduceSiteFilter(f lvst.Filter, site string) (rest lvst.Filter, decided, match bool) {
+	switch f := f.(type) {
+	case nil:
+		return nil, true, true
+	case lvst.FilterRule:
+		if f.Column == siteColumn {
+			return nil, true, f.Match(lvst.Record{siteColumn: site})
+		}
+		return f, false, false
+	case lvst.FilterAnd:
+		var fs lvst.FilterAnd
+		for _, e := range f {
+			r, decided, match := reduceSiteFilter(e, site)
+			if decided && !match {
+				return nil, true, false
+			}
+			if !decided {
+				fs = append(fs, r)
+			}
+		}
+		switch len(fs) {
+		case 0:
+			return nil, true, true
+		case 1:
+			return fs[0], false, false
+		}
+		return fs, false, false
+	case lvst.FilterOr:
+		var fs lvst.FilterOr
+		for _, e := range f {
+			r, decided, match := reduceSiteFilter(e, site)
+			if decided && match {
+				return nil, true, true
+			}
+			if !decided {
+				fs = append(fs, r)
+			}
+		}
+		switch len(fs) {
+		case 0:
+			return nil, true, false
+		case 1:
+			return fs[0], false, false
+		}
+		return fs, false, false
+	case lvst.FilterNot:
+		r, decided, match := reduceSiteFilter(f.Filter, site)
+		if decided {
+			return nil, true, !match
+		}
+		return lvst.FilterNot{Filter: r}, false, false
+	}
+	return f, false, false
+}
+
+// command routes an external command to the sites it applies to. Commands
+// on a host, or its services, are sent to the sites monitoring the host,
+// while global commands are sent to every site.
+func (p *proxy) command(ctx context.Context, line string) {
+	// Drop the timestamp, the command is sent with a new one
+	if i := strings.Index(line, "] "); strings.HasPrefix(line, "[") && i != -1 {
+		line = line[i+2:]
+	}
+	fields := strings.Split(line, ";")
+	name, args := fields[0], fields[1:]
+	if len(args) == 1 && args[0] == "" {
+		args = nil
+	}
+
+	targets := p.sites
+	argNames, _ := nagios.CommandArgs(name)
+	switch {
+	case len(argNames) > 0 && argNames[0] == "host_name" && len(args) > 0:
+		targets = p.owners(ctx, args[0])
+		if len(targets) == 0 {
+			log.Printf("no site monitors host %s, dropping command %s", args[0], name)
+		}
+	case containsAny(argNames, "comment_id", "downtime_id"):
+		// Identifiers are specific to each site
+		log.Printf("cannot route command %s by identifier, dropping it", name)
+		return
+	}
+
+	for _, s := range targets {
+		if err := s.command(ctx, name, args); err != nil {
+			log.Printf("error sending command %s to site %s, %v", name, s.name, err)
+		}
+	}
+}
+
+// owners returns the sites monitoring a host.
+func (p *proxy) owners(ctx context.Context, host string) []*site {
+	found := make([]bool, len(p.sites))
+
+	var wg sync.WaitGroup
+	for i, s := range p.sites {
+		wg.Add(1)
+		go func(i int, s *site) {
+			defer wg.Done()
+			resp, err := s.query(ctx, "hosts", func(q *lvst.Query) {
+				q.Columns("name").Filter("name = " + host)
+			})
+			if err != nil {
+				log.Printf("error querying site %s, %v", s.name, err)
+				return
+			}
+			found[i] = len(resp.Records) > 0
+		}(i, s)
+	}
+	wg.Wait()
+
+	var res []*site
+	for i, s := range p.sites {
+		if found[i] {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func containsAny(l []string, vals ...string) bool {
+	for _, e := range l {
+		for _, v := range vals {
+			if e == v {
+				return true
+			}
+		}
+	}
+	return false
+}
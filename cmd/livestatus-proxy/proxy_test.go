@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lvst "github.com/tcolgate/go-livestatus"
+	"github.com/tcolgate/go-livestatus/server"
+)
+
+// hosts is a static hosts table served by the test sites.
+type hosts []lvst.Record
+
+func (hosts) Columns() []server.Column {
+	return []server.Column{
+		{Name: "name", Type: "string"},
+		{Name: "state", Type: "int"},
+		{Name: "latency", Type: "float"},
+	}
+}
+
+func (t hosts) Records(ctx context.Context, req *server.Request) ([]lvst.Record, error) {
+	return t, nil
+}
+
+// testSite is a backend site, recording the filters, commands and
+// connections it receives.
+type testSite struct {
+	sync.Mutex
+	srv      *server.Server
+	filters  []string
+	commands []string
+	dials    int
+}
+
+// recordedHosts is a hosts table recording the filters of its queries.
+type recordedHosts struct {
+	hosts
+	ts *testSite
+}
+
+func (t recordedHosts) Records(ctx context.Context, req *server.Request) ([]lvst.Record, error) {
+	t.ts.Lock()
+	t.ts.filters = append(t.ts.filters, fmt.Sprintf("%#v", req.Filter))
+	t.ts.Unlock()
+	return t.hosts, nil
+}
+
+func newTestSite(records hosts) *testSite {
+	ts := &testSite{srv: server.New()}
+	ts.srv.Register("hosts", recordedHosts{records, ts})
+	ts.srv.OnCommand = func(ctx context.Context, cmd string) {
+		ts.Lock()
+		ts.commands = append(ts.commands, cmd[strings.Index(cmd, "] ")+2:])
+		ts.Unlock()
+	}
+	return ts
+}
+
+func (ts *testSite) livestatus() *lvst.Livestatus {
+	return lvst.NewLivestatusWithDialer(func() (net.Conn, error) {
+		ts.Lock()
+		ts.dials++
+		ts.Unlock()
+
+		client, server := net.Pipe()
+		go ts.srv.ServeConn(context.Background(), server)
+		return client, nil
+	})
+}
+
+func (ts *testSite) Filters() []string {
+	ts.Lock()
+	defer ts.Unlock()
+	return append([]string{}, ts.filters...)
+}
+
+func (ts *testSite) Commands() []string {
+	// Commands get no response, give the site a chance to handle them
+	time.Sleep(10 * time.Millisecond)
+	ts.Lock()
+	defer ts.Unlock()
+	return append([]string{}, ts.commands...)
+}
+
+func newTestProxy() (*lvst.Livestatus, *testSite, *testSite) {
+	paris := newTestSite(hosts{
+		{"name": "db1", "state": 0, "latency": 0.5},
+		{"name": "db2", "state": 1, "latency": 1.5},
+	})
+	lyon := newTestSite(hosts{
+		{"name": "web1", "state": 0, "latency": 0.25},
+	})
+
+	p := &proxy{
+		timeout: time.Second,
+		sites: []*site{
+			newSite("paris", 1, paris.livestatus),
+			newSite("lyon", 1, lyon.livestatus),
+		},
+	}
+
+	ls := lvst.NewLivestatusWithDialer(func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go p.serveConn(server)
+		return client, nil
+	})
+	return ls, paris, lyon
+}
+
+func Test_ProxyQuery(t *testing.T) {
+	ls, paris, _ := newTestProxy()
+
+	resp, err := ls.Query("hosts").Columns("site", "name").Filter("state = 0").Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []lvst.Record{
+		lvst.Record{"site": "paris", "name": "db1"},
+		lvst.Record{"site": "lyon", "name": "web1"},
+	}
+	if !reflect.DeepEqual(resp.Records, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Records)
+		t.Fail()
+	}
+
+	// Limits apply to the merged records
+	resp, err = ls.Query("hosts").Columns("name").Limit(2).Exec()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(resp.Records) != 2 {
+		t.Logf("\nExpected 2 records\nbut got  %#v\n", resp.Records)
+		t.Fail()
+	}
+
+	// All columns include the site
+	resp, err = ls.Query("hosts").Filter("name = web1").Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []lvst.Record{
+		lvst.Record{"site": "lyon", "name": "web1", "state": 0.0, "latency": 0.25},
+	}
+	if !reflect.DeepEqual(resp.Records, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Records)
+		t.Fail()
+	}
+	if cols := []string{"name", "state", "latency", "site"}; !reflect.DeepEqual(resp.Columns, cols) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", cols, resp.Columns)
+		t.Fail()
+	}
+
+	// Site connections are kept open across queries
+	paris.Lock()
+	defer paris.Unlock()
+	if paris.dials != 1 {
+		t.Logf("\nExpected a single connection to the site\nbut got  %d\n", paris.dials)
+		t.Fail()
+	}
+}
+
+func Test_ProxyStats(t *testing.T) {
+	ls, _, _ := newTestProxy()
+
+	resp, err := ls.Query("hosts").
+		Columns("state").
+		Stats("name ~ ^db").
+		Stats("sum latency").
+		Stats("max latency").
+		Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []lvst.Record{
+		lvst.Record{"state": 0.0, "stats_1": 1.0, "stats_2": 0.75, "stats_3": 0.5},
+		lvst.Record{"state": 1.0, "stats_1": 1.0, "stats_2": 1.5, "stats_3": 1.5},
+	}
+	if !reflect.DeepEqual(resp.Records, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Records)
+		t.Fail()
+	}
+
+	_, err = ls.Query("hosts").Stats("avg latency").Exec()
+	if serr, ok := err.(*lvst.StatusError); !ok || serr.Status != 452 {
+		t.Logf("\nExpected status 452 error\nbut got  %#v\n", err)
+		t.Fail()
+	}
+}
+
+func Test_ProxyStatsEmptySite(t *testing.T) {
+	ls, _, _ := newTestProxy()
+
+	// Lyon has no matching host, and answers with a min and max of 0
+	resp, err := ls.Query("hosts").
+		Filter("name ~ ^db").
+		Stats("min latency").
+		Stats("max latency").
+		Stats("sum latency").
+		Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []lvst.Record{
+		lvst.Record{"stats_1": 0.5, "stats_2": 1.5, "stats_3": 2.0},
+	}
+	if !reflect.DeepEqual(resp.Records, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Records)
+		t.Fail()
+	}
+}
+
+func Test_ProxySiteFilter(t *testing.T) {
+	ls, paris, lyon := newTestProxy()
+
+	tests := []struct {
+		query    *lvst.Query
+		expected []string
+	}{
+		{ls.Query("hosts").Columns("name").Filter("site = lyon"), []string{"web1"}},
+		{ls.Query("hosts").Columns("name").Filter("site != lyon").Filter("state = 1"), []string{"db2"}},
+		{ls.Query("hosts").Columns("name").Filter("site = lyon").Filter("name = db1").Or(2), []string{"db1", "web1"}},
+		{ls.Query("hosts").Columns("name").Filter("site = nowhere"), nil},
+	}
+
+	for _, tt := range tests {
+		resp, err := tt.query.Exec()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result []string
+		for _, r := range resp.Records {
+			result = append(result, r["name"].(string))
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Logf("\nExpected %q for %q\nbut got  %q\n", tt.expected, tt.query, result)
+			t.Fail()
+		}
+	}
+
+	// The site rules are not forwarded
+	for _, ts := range []*testSite{paris, lyon} {
+		for _, f := range ts.Filters() {
+			if strings.Contains(f, `"site"`) {
+				t.Logf("\nUnexpected site rule in %s\n", f)
+				t.Fail()
+			}
+		}
+	}
+}
+
+func Test_ProxyErrors(t *testing.T) {
+	ls, _, _ := newTestProxy()
+
+	_, err := ls.Query("nosuchtable").Exec()
+	if serr, ok := err.(*lvst.StatusError); !ok || serr.Status != 404 {
+		t.Logf("\nExpected status 404 error\nbut got  %#v\n", err)
+		t.Fail()
+	}
+
+	_, err = ls.Query("hosts").Filter("state").Exec()
+	if serr, ok := err.(*lvst.StatusError); !ok || serr.Status != 400 {
+		t.Logf("\nExpected status 400 error\nbut got  %#v\n", err)
+		t.Fail()
+	}
+}
+
+func Test_ProxyCommands(t *testing.T) {
+	ls, paris, lyon := newTestProxy()
+
+	for _, op := range []lvst.CommandOpFunc{
+		func(c *lvst.Command) { c.Raw("DISABLE_HOST_CHECK"); c.Arg("web1") },
+		func(c *lvst.Command) { c.Raw("DISABLE_NOTIFICATIONS") },
+		func(c *lvst.Command) { c.Raw("DEL_HOST_COMMENT"); c.Arg(1) },
+	} {
+		c := ls.Command()
+		c.Op(op)
+		if _, err := c.Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ls.Close()
+
+	expected := []string{"DISABLE_NOTIFICATIONS;"}
+	if result := paris.Commands(); !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+
+	expected = []string{"DISABLE_HOST_CHECK;web1", "DISABLE_NOTIFICATIONS;"}
+	if result := lyon.Commands(); !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+// filterer is the part of the query API used to emit filters, implemented by
+// *lvst.Query.
+type filterer interface {
+	Filter(rule string) *lvst.Query
+	And(n int) *lvst.Query
+	Or(n int) *lvst.Query
+	Negate() *lvst.Query
+}
+
+// expr is a node of a parsed filter expression.
+type expr interface {
+	apply(f filterer)
+}
+
+type condExpr struct {
+	column, op, value string
+}
+
+func (e condExpr) apply(f filterer) {
+	f.Filter(strings.TrimSpace(e.column + " " + e.op + " " + e.value))
+}
+
+type andExpr []expr
+
+func (e andExpr) apply(f filterer) {
+	for _, c := range e {
+		c.apply(f)
+	}
+	f.And(len(e))
+}
+
+type orExpr []expr
+
+func (e orExpr) apply(f filterer) {
+	for _, c := range e {
+		c.apply(f)
+	}
+	f.Or(len(e))
+}
+
+type notExpr struct {
+	expr
+}
+
+func (e notExpr) apply(f filterer) {
+	e.expr.apply(f)
+	f.Negate()
+}
+
+var operators = map[string]bool{
+	"=": true, "!=": true, "~": true, "!~": true, "=~": true, "!=~": true,
+	"~~": true, "!~~": true, "<": true, ">": true, "<=": true, ">=": true,
+}
+
+// parseExpr parses a simple filter expression such as
+//
+//	state != 0 and (acknowledged = 0 or host_name ~ "^db[0-9]+")
+//
+// into filters. Conditions are a column, an operator and a value, which must
+// be quoted if it contains whitespace or parentheses. Conditions may be
+// combined with and, or, not and parentheses.
+func parseExpr(s string) (expr, error) {
+	toks, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{toks: toks}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q in expression", p.toks[p.pos].text)
+	}
+	return e, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			toks = append(toks, token{text: string(c)})
+			i++
+		case c == '"':
+			var sb strings.Builder
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				sb.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated quote in expression")
+			}
+			toks = append(toks, token{text: sb.String(), quoted: true})
+			i++
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n()\"", rune(s[j])) {
+				j++
+			}
+			toks = append(toks, token{text: s[i:j]})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+type exprParser struct {
+	toks []token
+	pos  int
+}
+
+func (p *exprParser) peek(kw string) bool {
+	if p.pos >= len(p.toks) || p.toks[p.pos].quoted {
+		return false
+	}
+	return strings.EqualFold(p.toks[p.pos].text, kw)
+}
+
+func (p *exprParser) parseOr() (expr, error) {
+	var es orExpr
+	for {
+		e, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		es = append(es, e)
+		if !p.peek("or") {
+			break
+		}
+		p.pos++
+	}
+	if len(es) == 1 {
+		return es[0], nil
+	}
+	return es, nil
+}
+
+func (p *exprParser) parseAnd() (expr, error) {
+	var es andExpr
+	for {
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		es = append(es, e)
+		if !p.peek("and") {
+			break
+		}
+		p.pos++
+	}
+	if len(es) == 1 {
+		return es[0], nil
+	}
+	return es, nil
+}
+
+func (p *exprParser) parseNot() (expr, error) {
+	switch {
+	case p.peek("not"):
+		p.pos++
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e}, nil
+	case p.peek("("):
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, fmt.Errorf("missing closing parenthesis in expression")
+		}
+		p.pos++
+		return e, nil
+	}
+	return p.parseCond()
+}
+
+func (p *exprParser) parseCond() (expr, error) {
+	if p.pos+2 > len(p.toks) {
+		return nil, fmt.Errorf("incomplete condition in expression")
+	}
+	col, op := p.toks[p.pos], p.toks[p.pos+1]
+	if col.quoted || col.text == "(" || col.text == ")" {
+		return nil, fmt.Errorf("expected column name, got %q", col.text)
+	}
+	if op.quoted || !operators[op.text] {
+		return nil, fmt.Errorf("unknown operator %q for column %s", op.text, col.text)
+	}
+	p.pos += 2
+
+	// An empty value is allowed, e.g. `contacts >= ""` or `parents =`
+	e := condExpr{column: col.text, op: op.text}
+	if p.pos < len(p.toks) {
+		v := p.toks[p.pos]
+		if v.quoted || (v.text != ")" && !p.peek("and") && !p.peek("or")) {
+			e.value = v.text
+			p.pos++
+		}
+	}
+	return e, nil
+}
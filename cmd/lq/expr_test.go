@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+// recorder collects the headers an expression emits.
+type recorder []string
+
+func (r *recorder) Filter(rule string) *lvst.Query {
+	*r = append(*r, "Filter: "+rule)
+	return nil
+}
+
+func (r *recorder) And(n int) *lvst.Query {
+	*r = append(*r, fmt.Sprintf("And: %d", n))
+	return nil
+}
+
+func (r *recorder) Or(n int) *lvst.Query {
+	*r = append(*r, fmt.Sprintf("Or: %d", n))
+	return nil
+}
+
+func (r *recorder) Negate() *lvst.Query {
+	*r = append(*r, "Negate:")
+	return nil
+}
+
+func Test_ParseExpr(t *testing.T) {
+	tests := map[string][]string{
+		"state = 2": {
+			"Filter: state = 2",
+		},
+		`state != 0 and (acknowledged = 0 or host_name ~ "^db [0-9]+")`: {
+			"Filter: state != 0",
+			"Filter: acknowledged = 0",
+			"Filter: host_name ~ ^db [0-9]+",
+			"Or: 2",
+			"And: 2",
+		},
+		"a = 1 or b = 2 and not c = 3": {
+			"Filter: a = 1",
+			"Filter: b = 2",
+			"Filter: c = 3",
+			"Negate:",
+			"And: 2",
+			"Or: 2",
+		},
+		"parents = and state >= 1": {
+			"Filter: parents =",
+			"Filter: state >= 1",
+			"And: 2",
+		},
+	}
+
+	for data, expected := range tests {
+		e, err := parseExpr(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := recorder{}
+		e.apply(&result)
+		if !reflect.DeepEqual([]string(result), expected) {
+			t.Logf("\nExpected %q\nbut got  %q\n", expected, result)
+			t.Fail()
+		}
+	}
+}
+
+func Test_ParseExprInvalid(t *testing.T) {
+	for _, data := range []string{
+		"state",
+		"state is 2",
+		"(state = 2",
+		"state = 2)",
+		`name = "unterminated`,
+		"state = 2 and",
+	} {
+		if _, err := parseExpr(data); err == nil {
+			t.Logf("\nExpected error for %q\n", data)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	lvst "github.com/tcolgate/go-livestatus"
+	"github.com/tcolgate/go-livestatus/nagios"
+)
+
+// stringList is a flag that may be given multiple times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+var (
+	network = flag.String("network", "unix", "network of the livestatus socket, unix or tcp")
+	address = flag.String("address", "/var/run/nagios/livestatus.sock", "address of the livestatus socket")
+	table   = flag.String("table", "", "table to query, may also be given as the first argument")
+	columns = flag.String("columns", "", "comma or space separated columns to retrieve")
+	where   = flag.String("where", "", "filter expression, e.g. 'state != 0 and (acknowledged = 0 or host_name ~ ^db)'")
+	limit   = flag.Int("limit", 0, "maximum number of rows to retrieve")
+	format  = flag.String("format", "table", "output format, one of table, json, csv or ndjson")
+	command = flag.String("command", "", "external command to send, with arguments given as name=value")
+	list    = flag.Bool("list-commands", false, "list the known external commands and their arguments")
+	filters stringList
+	stats   stringList
+)
+
+func main() {
+	flag.Var(&filters, "filter", "raw filter rule, e.g. 'state = 2', may be repeated")
+	flag.Var(&stats, "stats", "raw stats rule, e.g. 'state = 0', may be repeated")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] table\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [flags] -command NAME [arg=value ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var err error
+	ls := lvst.NewLivestatus(*network, *address)
+
+	switch {
+	case *list:
+		listCommands(os.Stdout)
+	case *command != "":
+		err = sendCommand(ls, *command, flag.Args())
+	default:
+		err = runQuery(ls, os.Stdout, flag.Args())
+	}
+	if err == errUsage {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("error, %v", err)
+	}
+}
+
+// errUsage is returned when the command line is invalid.
+var errUsage = errors.New("invalid usage")
+
+func runQuery(ls *lvst.Livestatus, w io.Writer, args []string) error {
+	tbl := *table
+	if tbl == "" && len(args) > 0 {
+		tbl, args = args[0], args[1:]
+	}
+	if tbl == "" || len(args) > 0 {
+		return errUsage
+	}
+
+	write, ok := writers[*format]
+	if !ok {
+		return fmt.Errorf("unknown output format %s", *format)
+	}
+
+	q := ls.Query(tbl)
+
+	cols := strings.FieldsFunc(*columns, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(cols) > 0 {
+		q.Columns(cols...)
+	}
+	for _, f := range filters {
+		q.Filter(f)
+	}
+	if *where != "" {
+		e, err := parseExpr(*where)
+		if err != nil {
+			return err
+		}
+		e.apply(q)
+	}
+	for _, s := range stats {
+		q.Stats(s)
+	}
+	if *limit > 0 {
+		q.Limit(*limit)
+	}
+
+	resp, err := q.Exec()
+	if err != nil {
+		return err
+	}
+
+	return write(w, resp.Columns, resp.Records)
+}
+
+func sendCommand(ls *lvst.Livestatus, name string, args []string) error {
+	named := map[string]string{}
+	for _, a := range args {
+		parts := strings.SplitN(a, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("command arguments must be given as name=value, got %q", a)
+		}
+		named[parts[0]] = parts[1]
+	}
+
+	op, err := nagios.ParseCommand(name, named)
+	if err != nil {
+		return err
+	}
+
+	defer ls.Close()
+
+	c := ls.Command()
+	c.Op(op)
+	_, err = c.Exec()
+	return err
+}
+
+func listCommands(w io.Writer) {
+	for _, n := range nagios.CommandNames() {
+		args, _ := nagios.CommandArgs(n)
+		fmt.Fprintf(w, "%s %s\n", n, strings.Join(args, " "))
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tcolgate/go-livestatus/internal/livestatustest"
+)
+
+func Test_RunQueryStatsOnly(t *testing.T) {
+	stats = stringList{"state = 0", "state != 0"}
+	*columns = ""
+	*format = "csv"
+	defer func() { stats = nil; *format = "table" }()
+
+	buf := &bytes.Buffer{}
+	if err := runQuery(livestatustest.NewLivestatus(map[string]string{"hosts": `[[12,3]]`}), buf, []string{"hosts"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "stats_1,stats_2\n12,3\n"
+	if result := buf.String(); result != expected {
+		t.Logf("\nExpected %q\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_RunQueryColumnOrder(t *testing.T) {
+	*format = "csv"
+	defer func() { *format = "table" }()
+
+	ls := livestatustest.NewLivestatus(map[string]string{
+		"hosts": `[["name","state","address"],["db1",0,"10.0.0.1"]]`,
+	})
+
+	buf := &bytes.Buffer{}
+	if err := runQuery(ls, buf, []string{"hosts"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "name,state,address\ndb1,0,10.0.0.1\n"
+	if result := buf.String(); result != expected {
+		t.Logf("\nExpected %q\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+
+	if err := runQuery(ls, buf, nil); err != errUsage {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", errUsage, err)
+		t.Fail()
+	}
+}
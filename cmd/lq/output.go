@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+var writers = map[string]func(io.Writer, []string, []lvst.Record) error{
+	"table":  writeTable,
+	"json":   writeJSON,
+	"csv":    writeCSV,
+	"ndjson": writeNDJSON,
+}
+
+// formatValue renders a record value as text. Numbers are printed without
+// exponents, lists and dicts as JSON.
+func formatValue(v interface{}) string {
+	switch vc := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return vc
+	case float64:
+		return strconv.FormatFloat(vc, 'f', -1, 64)
+	default:
+		bs, err := json.Marshal(vc)
+		if err != nil {
+			return fmt.Sprintf("%v", vc)
+		}
+		return string(bs)
+	}
+}
+
+func writeTable(w io.Writer, cols []string, rs []lvst.Record) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(cols, "\t"))
+	for _, r := range rs {
+		vals := make([]string, len(cols))
+		for i, c := range cols {
+			// Keep each record on a single row
+			vals[i] = strings.NewReplacer("\t", " ", "\n", `\n`).Replace(formatValue(r[c]))
+		}
+		fmt.Fprintln(tw, strings.Join(vals, "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, cols []string, rs []lvst.Record) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(cols); err != nil {
+		return err
+	}
+	for _, r := range rs {
+		vals := make([]string, len(cols))
+		for i, c := range cols {
+			vals[i] = formatValue(r[c])
+		}
+		if err := cw.Write(vals); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, cols []string, rs []lvst.Record) error {
+	objs := make([]map[string]interface{}, len(rs))
+	for i, r := range rs {
+		objs[i] = selectColumns(cols, r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(objs)
+}
+
+func writeNDJSON(w io.Writer, cols []string, rs []lvst.Record) error {
+	enc := json.NewEncoder(w)
+	for _, r := range rs {
+		if err := enc.Encode(selectColumns(cols, r)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func selectColumns(cols []string, r lvst.Record) map[string]interface{} {
+	obj := make(map[string]interface{}, len(cols))
+	for _, c := range cols {
+		obj[c] = r[c]
+	}
+	return obj
+}
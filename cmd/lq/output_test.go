@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+var records = []lvst.Record{
+	lvst.Record{"name": "db1", "state": 0.0, "groups": []interface{}{"db", "linux"}},
+	lvst.Record{"name": "web1", "state": 1.0, "groups": []interface{}{}},
+}
+
+func Test_Writers(t *testing.T) {
+	cols := []string{"name", "state", "groups"}
+
+	tests := map[string]string{
+		"table": "name  state  groups\n" +
+			"db1   0      [\"db\",\"linux\"]\n" +
+			"web1  1      []\n",
+		"csv": "name,state,groups\n" +
+			"db1,0,\"[\"\"db\"\",\"\"linux\"\"]\"\n" +
+			"web1,1,[]\n",
+		"ndjson": `{"groups":["db","linux"],"name":"db1","state":0}` + "\n" +
+			`{"groups":[],"name":"web1","state":1}` + "\n",
+	}
+
+	for format, expected := range tests {
+		buf := &bytes.Buffer{}
+		if err := writers[format](buf, cols, records); err != nil {
+			t.Fatal(err)
+		}
+		if result := buf.String(); result != expected {
+			t.Logf("\n%s: Expected %q\nbut got  %q\n", format, expected, result)
+			t.Fail()
+		}
+	}
+}
+
+func Test_WriteJSONEmpty(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := writeJSON(buf, []string{"name"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if result := buf.String(); result != "[]\n" {
+		t.Logf("\nExpected %q\nbut got  %q\n", "[]\n", result)
+		t.Fail()
+	}
+}
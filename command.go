@@ -1,6 +1,7 @@
 package livestatus
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strings"
@@ -25,6 +26,8 @@ type Command struct {
 	cmd  string
 	vals []string
 	ls   *Livestatus
+
+	idempotent bool
 }
 
 func newCommand(ls *Livestatus) *Command {
@@ -49,60 +52,142 @@ func (c *Command) Op(op CommandOpFunc) {
 
 // KeepAliveOff disables the default keepalive from Command
 func (q *Query) KeepAliveOff() *Query {
-	q.ls.keepalive = false
+	q.ls.setKeepAlive(false)
 	return q
 }
 
+// Idempotent marks the command as safe to send again should sending it fail,
+// allowing the retry policy of the instance to retry it.
+func (c *Command) Idempotent() {
+	c.idempotent = true
+}
+
 // Exec executes the query.
 func (c *Command) Exec() (*Response, error) {
+	return c.ExecContext(context.Background())
+}
+
+// ExecContext executes the command. Idempotent commands are retried as set by
+// the retry policy of the instance, as long as the context allows it. Commands
+// fail fast while the circuit of the breaker of the instance is open.
+func (c *Command) ExecContext(ctx context.Context) (*Response, error) {
+	if c.ls.breaker == nil {
+		return c.execRetry(ctx)
+	}
+	if err := c.ls.breaker.allow(ctx, c.ls); err != nil {
+		return nil, err
+	}
+	resp, err := c.execRetry(ctx)
+	c.ls.breaker.record(err)
+	return resp, err
+}
+
+func (c *Command) execRetry(ctx context.Context) (*Response, error) {
+	if c.ls.retry == nil || !c.idempotent {
+		return c.exec(ctx)
+	}
+
+	var resp *Response
+	err := c.ls.retry.do(ctx, "command", c.cmd, func() error {
+		var err error
+		resp, err = c.exec(ctx)
+		return err
+	})
+	return resp, err
+}
+
+func (c *Command) exec(ctx context.Context) (*Response, error) {
 	resp := &Response{}
 
-	var err error
-	var conn net.Conn
+	cmd, err := c.buildCmd(time.Now())
+	if err != nil {
+		return nil, err
+	}
 
-	if c.ls.keepConn != nil {
-		conn = c.ls.keepConn
+	conn, keep := c.ls.takeConn()
+	if conn != nil {
 		connectReuseCount.
 			WithLabelValues(conn.RemoteAddr().String()).
 			Inc()
 	} else {
 		// Connect to socket
-		conn, err = c.dial()
+		conn, err = c.dial(ctx)
 		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
 			return nil, err
 		}
 	}
 
-	if !c.ls.keepalive {
-		c.ls.keepConn = nil
-		defer conn.Close()
-	} else {
-		c.ls.keepConn = conn
+	// Connections are only kept once the command has been sent
+	sent := false
+	defer func() {
+		if keep && sent {
+			c.ls.putConn(conn)
+			return
+		}
+		conn.Close()
+	}()
+
+	// Unblock a pending write once the context is done
+	if ctx.Done() != nil {
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		defer func() {
+			close(stop)
+			<-done
+			// Clear any deadline set as the command completed, as the
+			// connection may be kept alive
+			conn.SetDeadline(time.Time{})
+		}()
+		go func() {
+			defer close(done)
+			select {
+			case <-ctx.Done():
+				conn.SetDeadline(time.Now())
+			case <-stop:
+			}
+		}()
 	}
 
 	// Send command data
-	cmd, err := c.buildCmd(time.Now())
-	if err != nil {
+	if _, err := conn.Write([]byte(cmd)); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
 		return nil, err
 	}
-
-	conn.Write([]byte(cmd))
+	sent = true
 	// You get nothing back from an external command
 	// no way of knowing if this has worked
 
 	commandCount.WithLabelValues(c.cmd).Inc()
 
+	if c.ls.cache != nil {
+		c.ls.cache.Invalidate(commandTables(c.cmd, c.vals)...)
+	}
+
 	return resp, nil
 }
 
 func (c *Command) buildCmd(t time.Time) (string, error) {
+	if strings.ContainsAny(c.cmd, "\r\n") {
+		return "", ErrLineBreak
+	}
+	for _, v := range c.vals {
+		if strings.ContainsAny(v, "\r\n") {
+			return "", ErrLineBreak
+		}
+	}
+
 	cmdStr := fmt.Sprintf("COMMAND [%d] %s", t.Unix(), c.cmd)
 	cmdStr = fmt.Sprintf("%s;%s", cmdStr, strings.Join(c.vals, ";"))
 
 	return fmt.Sprintf("%s\n", cmdStr), nil
 }
 
-func (c *Command) dial() (cc net.Conn, err error) {
+func (c *Command) dial(ctx context.Context) (cc net.Conn, err error) {
 	defer func() {
 		if err == nil {
 			connectCount.
@@ -118,6 +203,7 @@ func (c *Command) dial() (cc net.Conn, err error) {
 	if c.ls.dialer != nil {
 		return c.ls.dialer()
 	} else {
-		return net.Dial(c.ls.network, c.ls.address)
+		d := net.Dialer{}
+		return d.DialContext(ctx, c.ls.network, c.ls.address)
 	}
 }
@@ -2,6 +2,8 @@ package livestatus
 
 import (
 	"errors"
+	"fmt"
+	"time"
 )
 
 // Record retrieval errors
@@ -9,3 +11,67 @@ var (
 	ErrUnknownColumn = errors.New("unknown record column")
 	ErrInvalidValue  = errors.New("invalid record value")
 )
+
+// ErrNoWatchKeys is returned when watching a table with no known key columns
+// and none were given.
+var ErrNoWatchKeys = errors.New("no key columns to identify watched objects")
+
+// ErrUnboundQuery is returned when executing a parsed query that was not
+// bound to a Livestatus instance.
+var ErrUnboundQuery = errors.New("query is not bound to a livestatus instance")
+
+// ErrInvalidToken is returned when paging with a continuation token not
+// returned by the paginator.
+var ErrInvalidToken = errors.New("invalid continuation token")
+
+// ErrNoPageKeys is returned when paginating a query with no key columns.
+var ErrNoPageKeys = errors.New("no key columns to order pages")
+
+// ErrUnorderedRecords is returned when paging through records Livestatus
+// does not return in key order.
+var ErrUnorderedRecords = errors.New("records not ordered by the paging keys")
+
+// ErrLineBreak is returned when sending a query header or command argument
+// holding a line break, which Livestatus would read as further headers or
+// commands.
+var ErrLineBreak = errors.New("line break in query header or command argument")
+
+// StatusError is returned when Livestatus answers a query with an error
+// status code, such as 404 for an unknown table or column.
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("livestatus error %d, %s", e.Status, e.Message)
+}
+
+// SchemaError is returned when a query refers to a table or a column unknown
+// to the schema of the Livestatus instance. Column is empty for unknown
+// tables.
+type SchemaError struct {
+	Table  string
+	Column string
+}
+
+func (e *SchemaError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("unknown table %s", e.Table)
+	}
+	return fmt.Sprintf("table %s has no column %s", e.Table, e.Column)
+}
+
+// CircuitOpenError is returned by queries and commands failed fast as the
+// circuit of their backend is open.
+type CircuitOpenError struct {
+	Backend string
+	// Since is the time the circuit opened.
+	Since time.Time
+	// Err is the last error of the backend.
+	Err error
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("livestatus backend %s unavailable since %s, %v", e.Backend, e.Since.Format(time.RFC3339), e.Err)
+}
@@ -0,0 +1,181 @@
+package livestatus
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Flatten is the way list and dict values are written as a single text
+// field.
+type Flatten int
+
+// Flattening modes of list and dict values
+const (
+	// FlattenJSON writes lists and dicts as JSON.
+	FlattenJSON Flatten = iota
+	// FlattenJoin joins the elements of lists, and the key=value pairs of
+	// dicts, with the list separator. The fields of nested lists, such as
+	// those of services_with_state, are joined with `|`.
+	FlattenJoin
+)
+
+// WriteOptions are the options of the response writers.
+type WriteOptions struct {
+	// Columns are the columns written, in order. They default to the
+	// columns of the query, or to those of the records sorted by name.
+	Columns []string
+	Flatten Flatten
+	// ListSep separates list elements with FlattenJoin, `,` by default.
+	ListSep string
+}
+
+// WriteCSV writes the records as CSV, preceded by a row of column names.
+func (r Response) WriteCSV(w io.Writer, opts *WriteOptions) error {
+	cols, flatten := r.writeOptions(opts)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(cols); err != nil {
+		return err
+	}
+	for i := 0; i < r.Len(); i++ {
+		vals := make([]string, len(cols))
+		for j, c := range cols {
+			vals[j] = flatten(r.Value(i, c))
+		}
+		if err := cw.Write(vals); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteTable writes the records as a text table with aligned columns,
+// preceded by a row of column names.
+func (r Response) WriteTable(w io.Writer, opts *WriteOptions) error {
+	cols, flatten := r.writeOptions(opts)
+
+	// Keep each record on a single row
+	escape := strings.NewReplacer("\t", " ", "\n", `\n`)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(cols, "\t"))
+	for i := 0; i < r.Len(); i++ {
+		vals := make([]string, len(cols))
+		for j, c := range cols {
+			vals[j] = escape.Replace(flatten(r.Value(i, c)))
+		}
+		fmt.Fprintln(tw, strings.Join(vals, "\t"))
+	}
+	return tw.Flush()
+}
+
+// WriteNDJSON writes each record as a JSON object on its own line. Only the
+// columns of the options are written when given.
+func (r Response) WriteNDJSON(w io.Writer, opts *WriteOptions) error {
+	cols := r.columnNames()
+	if opts != nil && len(opts.Columns) > 0 {
+		cols = opts.Columns
+	}
+
+	enc := json.NewEncoder(w)
+	for i := 0; i < r.Len(); i++ {
+		obj := make(map[string]interface{}, len(cols))
+		for _, c := range cols {
+			obj[c] = r.Value(i, c)
+		}
+		if err := enc.Encode(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnNames returns the columns of the query the response is for, or the
+// sorted columns of the records when unknown.
+func (r Response) columnNames() []string {
+	if len(r.Columns) > 0 {
+		return r.Columns
+	}
+
+	seen := map[string]bool{}
+	var cols []string
+	for _, rec := range r.Records {
+		for c := range rec {
+			if !seen[c] {
+				seen[c] = true
+				cols = append(cols, c)
+			}
+		}
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+func (r Response) writeOptions(opts *WriteOptions) ([]string, func(interface{}) string) {
+	if opts == nil {
+		opts = &WriteOptions{}
+	}
+
+	cols := opts.Columns
+	if len(cols) == 0 {
+		cols = r.columnNames()
+	}
+
+	sep := opts.ListSep
+	if sep == "" {
+		sep = ","
+	}
+
+	if opts.Flatten == FlattenJoin {
+		return cols, func(v interface{}) string { return joinValue(v, sep) }
+	}
+	return cols, jsonValue
+}
+
+// jsonValue formats a value as text, with lists and dicts as JSON.
+func jsonValue(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return ""
+	case []interface{}, map[string]interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+	return formatValue(v)
+}
+
+// joinValue formats a value as text, with list elements and dict pairs
+// joined by sep, and the fields of nested lists by `|`.
+func joinValue(v interface{}, sep string) string {
+	switch vc := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		strs := make([]string, len(vc))
+		for i, e := range vc {
+			strs[i] = joinValue(e, "|")
+		}
+		return strings.Join(strs, sep)
+	case map[string]interface{}:
+		var keys []string
+		for k := range vc {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		strs := make([]string, len(keys))
+		for i, k := range keys {
+			strs[i] = k + "=" + joinValue(vc[k], "|")
+		}
+		return strings.Join(strs, sep)
+	}
+	return formatValue(v)
+}
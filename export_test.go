@@ -0,0 +1,85 @@
+package livestatus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func exportResponse(t *testing.T) *Response {
+	ls, _ := newFakeLivestatus(fixtureHandler(map[string]string{
+		"hosts": `[
+			["web1",0,["linux","web"],{"ROLE":"front"},[["HTTP",0,1],["Load",2,1]]],
+			["db1",1.5,[],{},[]]
+		]`,
+	}))
+
+	resp, err := ls.Query("hosts").Columns("name", "state", "groups", "custom_variables", "services_with_state").Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func Test_ResponseWriteCSV(t *testing.T) {
+	resp := exportResponse(t)
+
+	buf := bytes.NewBuffer(nil)
+	if err := resp.WriteCSV(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "name,state,groups,custom_variables,services_with_state\n" +
+		`web1,0,"[""linux"",""web""]","{""ROLE"":""front""}","[[""HTTP"",0,1],[""Load"",2,1]]"` + "\n" +
+		"db1,1.5,[],{},[]\n"
+	if result := buf.String(); result != expected {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	buf.Reset()
+	opts := &WriteOptions{Columns: []string{"groups", "name", "services_with_state", "custom_variables"}, Flatten: FlattenJoin, ListSep: ";"}
+	if err := resp.WriteCSV(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "groups,name,services_with_state,custom_variables\n" +
+		"linux;web,web1,HTTP|0|1;Load|2|1,ROLE=front\n" +
+		",db1,,\n"
+	if result := buf.String(); result != expected {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_ResponseWriteTable(t *testing.T) {
+	resp := exportResponse(t)
+
+	buf := bytes.NewBuffer(nil)
+	if err := resp.WriteTable(buf, &WriteOptions{Columns: []string{"name", "state", "groups"}, Flatten: FlattenJoin}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "name  state  groups\n" +
+		"web1  0      linux,web\n" +
+		"db1   1.5    \n"
+	if result := buf.String(); result != expected {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_ResponseWriteNDJSON(t *testing.T) {
+	resp := exportResponse(t)
+
+	buf := bytes.NewBuffer(nil)
+	if err := resp.WriteNDJSON(buf, &WriteOptions{Columns: []string{"name", "groups"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"groups":["linux","web"],"name":"web1"}` + "\n" +
+		`{"groups":[],"name":"db1"}` + "\n"
+	if result := buf.String(); result != expected {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+}
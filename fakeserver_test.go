@@ -0,0 +1,92 @@
+package livestatus
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// fakeServer answers the queries of a Livestatus instance over in-memory
+// pipes, recording every query and command it receives. Connections are
+// closed after each query, unless kept alive.
+type fakeServer struct {
+	sync.Mutex
+	handler  func(req string) (int, string)
+	queries  []string
+	commands []string
+}
+
+func newFakeLivestatus(handler func(req string) (int, string)) (*Livestatus, *fakeServer) {
+	s := &fakeServer{handler: handler}
+	ls := NewLivestatusWithDialer(func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go s.serve(server)
+		return client, nil
+	})
+	return ls, s
+}
+
+// fixtureHandler answers each query with the fixture of the queried table.
+func fixtureHandler(fixtures map[string]string) func(string) (int, string) {
+	return func(req string) (int, string) {
+		table := strings.TrimPrefix(strings.SplitN(req, "\n", 2)[0], "GET ")
+		body, ok := fixtures[table]
+		if !ok {
+			return 404, "Invalid GET request, no such table '" + table + "'"
+		}
+		return 200, body
+	}
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	rd := bufio.NewReader(conn)
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		if strings.HasPrefix(line, "COMMAND ") {
+			s.Lock()
+			s.commands = append(s.commands, strings.TrimSuffix(line, "\n"))
+			s.Unlock()
+			continue
+		}
+
+		// Read the query headers up to the blank line ending the request
+		req := line
+		for line != "\n" {
+			if line, err = rd.ReadString('\n'); err != nil {
+				return
+			}
+			req += line
+		}
+
+		s.Lock()
+		s.queries = append(s.queries, req)
+		s.Unlock()
+
+		status, body := s.handler(req)
+		body += "\n"
+		fmt.Fprintf(conn, "%03d %11d\n%s", status, len(body), body)
+		if !strings.Contains(req, "\nKeepAlive: on\n") {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) Queries() []string {
+	s.Lock()
+	defer s.Unlock()
+	return append([]string{}, s.queries...)
+}
+
+func (s *fakeServer) Commands() []string {
+	s.Lock()
+	defer s.Unlock()
+	return append([]string{}, s.commands...)
+}
@@ -0,0 +1,403 @@
+package livestatus
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Filter is a filter expression on records, as built by the Filter, And, Or
+// and Negate headers of a query.
+type Filter interface {
+	// Match reports whether a record matches the filter.
+	Match(r Record) bool
+
+	// apply adds the headers expressing the filter to a query.
+	apply(q *Query, h filterHeaders)
+}
+
+// filterHeaders holds the names of the headers used to express a filter,
+// which differ between filters and stats.
+type filterHeaders struct {
+	rule, and, or, negate string
+}
+
+var (
+	filterHeaderNames = filterHeaders{"Filter", "And", "Or", "Negate"}
+	statsHeaderNames  = filterHeaders{"Stats", "StatsAnd", "StatsOr", "StatsNegate"}
+)
+
+// FilterRule is a single filter rule, such as `state >= 1`, comparing a
+// column to a value.
+type FilterRule struct {
+	Column string
+	Op     string
+	Value  string
+}
+
+// FilterAnd matches records matching all of its filters.
+type FilterAnd []Filter
+
+// FilterOr matches records matching any of its filters.
+type FilterOr []Filter
+
+// FilterNot matches records not matching its filter.
+type FilterNot struct {
+	Filter
+}
+
+var filterOps = map[string]bool{
+	"=": true, "!=": true, "~": true, "!~": true, "=~": true, "!=~": true,
+	"~~": true, "!~~": true, "<": true, ">": true, "<=": true, ">=": true,
+}
+
+// ParseFilterRule parses a filter rule of the form `column op value`.
+func ParseFilterRule(s string) (FilterRule, error) {
+	fields := strings.SplitN(strings.TrimSpace(s), " ", 3)
+	if len(fields) < 2 || !filterOps[fields[1]] {
+		return FilterRule{}, fmt.Errorf("invalid filter rule %q", s)
+	}
+
+	f := FilterRule{Column: fields[0], Op: fields[1]}
+	if len(fields) == 3 {
+		f.Value = fields[2]
+	}
+
+	if op := strings.TrimPrefix(f.Op, "!"); op == "~" || op == "~~" {
+		if _, err := compileRegexp(f.regexpExpr()); err != nil {
+			return FilterRule{}, fmt.Errorf("invalid filter rule %q, %v", s, err)
+		}
+	}
+
+	return f, nil
+}
+
+// String returns the rule as given in a Filter header.
+func (f FilterRule) String() string {
+	return strings.TrimSpace(f.Column + " " + f.Op + " " + f.Value)
+}
+
+// Match reports whether a record matches the rule. Records lacking the
+// column never match.
+func (f FilterRule) Match(r Record) bool {
+	v, ok := r[f.Column]
+	if !ok {
+		return false
+	}
+
+	op := f.Op
+	negate := strings.HasPrefix(op, "!")
+	if negate {
+		op = op[1:]
+	}
+
+	var res bool
+	switch l := v.(type) {
+	case []interface{}:
+		res = f.matchList(op, l)
+	case []string:
+		il := make([]interface{}, len(l))
+		for i, e := range l {
+			il[i] = e
+		}
+		res = f.matchList(op, il)
+	default:
+		res = f.matchValue(op, v)
+	}
+
+	if negate {
+		return !res
+	}
+	return res
+}
+
+// matchList matches list columns, where >= tests whether the list contains
+// the value and < whether it doesn't, <= and > doing the same ignoring case,
+// and = and != with an empty value test for an empty list.
+func (f FilterRule) matchList(op string, l []interface{}) bool {
+	switch op {
+	case "=":
+		if f.Value == "" {
+			return len(l) == 0
+		}
+		return false
+	case ">=", "<":
+		contains := false
+		for _, e := range l {
+			contains = contains || formatValue(e) == f.Value
+		}
+		return contains == (op == ">=")
+	case "<=", "=~", ">":
+		contains := false
+		for _, e := range l {
+			contains = contains || strings.EqualFold(formatValue(e), f.Value)
+		}
+		return contains == (op != ">")
+	case "~", "~~":
+		re := f.regexp()
+		for _, e := range l {
+			if re != nil && re.MatchString(formatValue(e)) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+func (f FilterRule) matchValue(op string, v interface{}) bool {
+	switch op {
+	case "~", "~~":
+		re := f.regexp()
+		return re != nil && re.MatchString(formatValue(v))
+	case "=~":
+		return strings.EqualFold(formatValue(v), f.Value)
+	}
+
+	cmp := 0
+	if n, ok := numericValue(v); ok {
+		fv, err := strconv.ParseFloat(f.Value, 64)
+		if err != nil {
+			return false
+		}
+		switch {
+		case n < fv:
+			cmp = -1
+		case n > fv:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(formatValue(v), f.Value)
+	}
+
+	switch op {
+	case "=":
+		return cmp == 0
+	case "<":
+		return cmp < 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// regexp returns the regular expression of the rule, or nil when invalid.
+func (f FilterRule) regexp() *regexp.Regexp {
+	re, _ := compileRegexp(f.regexpExpr())
+	return re
+}
+
+func (f FilterRule) regexpExpr() string {
+	if strings.HasSuffix(f.Op, "~~") {
+		return "(?i)" + f.Value
+	}
+	return f.Value
+}
+
+// maxCachedRegexps bounds the number of compiled expressions kept.
+const maxCachedRegexps = 256
+
+// regexpCache holds the compiled expressions of regular expression rules,
+// which can't hold them as rules are values, often built without parsing.
+var regexpCache = struct {
+	sync.Mutex
+	m map[string]cachedRegexp
+}{m: map[string]cachedRegexp{}}
+
+type cachedRegexp struct {
+	re  *regexp.Regexp
+	err error
+}
+
+// compileRegexp compiles an expression, returning the result of a previous
+// compilation when available.
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	regexpCache.Lock()
+	defer regexpCache.Unlock()
+
+	c, ok := regexpCache.m[expr]
+	if !ok {
+		if len(regexpCache.m) >= maxCachedRegexps {
+			regexpCache.m = map[string]cachedRegexp{}
+		}
+		c.re, c.err = regexp.Compile(expr)
+		regexpCache.m[expr] = c
+	}
+	return c.re, c.err
+}
+
+func (f FilterRule) apply(q *Query, h filterHeaders) {
+	q.headers = append(q.headers, h.rule+": "+f.String())
+}
+
+// Match reports whether a record matches all the filters.
+func (fs FilterAnd) Match(r Record) bool {
+	for _, f := range fs {
+		if !f.Match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (fs FilterAnd) apply(q *Query, h filterHeaders) {
+	for _, f := range fs {
+		f.apply(q, h)
+	}
+	q.headers = append(q.headers, fmt.Sprintf("%s: %d", h.and, len(fs)))
+}
+
+// Match reports whether a record matches any of the filters.
+func (fs FilterOr) Match(r Record) bool {
+	for _, f := range fs {
+		if f.Match(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (fs FilterOr) apply(q *Query, h filterHeaders) {
+	for _, f := range fs {
+		f.apply(q, h)
+	}
+	q.headers = append(q.headers, fmt.Sprintf("%s: %d", h.or, len(fs)))
+}
+
+// Match reports whether a record does not match the filter.
+func (f FilterNot) Match(r Record) bool {
+	return !f.Filter.Match(r)
+}
+
+func (f FilterNot) apply(q *Query, h filterHeaders) {
+	f.Filter.apply(q, h)
+	q.headers = append(q.headers, h.negate+":")
+}
+
+// FilterStack builds a filter expression from a sequence of filter headers,
+// as a Livestatus server does.
+type FilterStack []Filter
+
+// Push adds a filter to the top of the stack.
+func (s *FilterStack) Push(f Filter) {
+	*s = append(*s, f)
+}
+
+// And replaces the n filters at the top of the stack by their conjunction.
+func (s *FilterStack) And(n int) error {
+	fs, err := s.pop(n)
+	if err != nil {
+		return err
+	}
+	s.Push(FilterAnd(fs))
+	return nil
+}
+
+// Or replaces the n filters at the top of the stack by their disjunction.
+func (s *FilterStack) Or(n int) error {
+	fs, err := s.pop(n)
+	if err != nil {
+		return err
+	}
+	s.Push(FilterOr(fs))
+	return nil
+}
+
+// Negate replaces the filter at the top of the stack by its negation.
+func (s *FilterStack) Negate() error {
+	fs, err := s.pop(1)
+	if err != nil {
+		return err
+	}
+	s.Push(FilterNot{fs[0]})
+	return nil
+}
+
+// Filter returns the conjunction of the filters left on the stack, or nil
+// if it is empty.
+func (s FilterStack) Filter() Filter {
+	switch len(s) {
+	case 0:
+		return nil
+	case 1:
+		return s[0]
+	}
+	return FilterAnd(append([]Filter{}, s...))
+}
+
+func (s *FilterStack) pop(n int) ([]Filter, error) {
+	if n < 0 || n > len(*s) {
+		return nil, fmt.Errorf("cannot combine %d filters, only %d available", n, len(*s))
+	}
+	fs := append([]Filter{}, (*s)[len(*s)-n:]...)
+	*s = (*s)[:len(*s)-n]
+	return fs, nil
+}
+
+// Where adds the headers expressing a filter expression to the query.
+func (q *Query) Where(f Filter) *Query {
+	f.apply(q, filterHeaderNames)
+	return q
+}
+
+// StatsWhere adds a statistics rule counting the records matching a filter
+// expression.
+func (q *Query) StatsWhere(f Filter) *Query {
+	f.apply(q, statsHeaderNames)
+	q.stats = true
+	return q
+}
+
+// numericValue returns the value of numeric record values, including times
+// as seconds since the epoch and booleans as 0 or 1.
+func numericValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case time.Time:
+		return float64(v.Unix()), true
+	case time.Duration:
+		return v.Seconds(), true
+	}
+	return 0, false
+}
+
+// formatValue returns the textual form of a record value, as it would be
+// compared against a filter value.
+func formatValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	if n, ok := numericValue(v); ok {
+		if n == math.Trunc(n) && math.Abs(n) < 1e15 {
+			return strconv.FormatInt(int64(n), 10)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,182 @@
+package livestatus
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_ParseFilterRule(t *testing.T) {
+	tests := map[string]FilterRule{
+		"state >= 1":               FilterRule{Column: "state", Op: ">=", Value: "1"},
+		"plugin_output = foo bar":  FilterRule{Column: "plugin_output", Op: "=", Value: "foo bar"},
+		"service_description =":    FilterRule{Column: "service_description", Op: "="},
+		"contact_groups >= admins": FilterRule{Column: "contact_groups", Op: ">=", Value: "admins"},
+	}
+
+	for data, expected := range tests {
+		result, err := ParseFilterRule(data)
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(result, expected) {
+			t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+			t.Fail()
+		} else if result.String() != data {
+			t.Logf("\nExpected %q\nbut got  %q\n", data, result.String())
+			t.Fail()
+		}
+	}
+
+	for _, data := range []string{"state", "state == 1", "name ~ ("} {
+		if _, err := ParseFilterRule(data); err == nil {
+			t.Logf("\nExpected error for %q\n", data)
+			t.Fail()
+		}
+	}
+}
+
+func Test_FilterRuleMatch(t *testing.T) {
+	r := Record{
+		"name":       "db1.example.net",
+		"state":      2.0,
+		"last_check": time.Unix(1439640000, 0),
+		"groups":     []interface{}{"db", "linux"},
+		"parents":    []interface{}{},
+		"active":     true,
+	}
+
+	tests := map[string]bool{
+		"name = db1.example.net":   true,
+		"name != db1.example.net":  false,
+		"name =~ DB1.EXAMPLE.NET":  true,
+		"name ~ ^db[0-9]":          true,
+		"name ~ ^DB":               false,
+		"name ~~ ^DB":              true,
+		"name !~ ^web":             true,
+		"name < e":                 true,
+		"state = 2":                true,
+		"state >= 1":               true,
+		"state < 2":                false,
+		"state > 10":               false,
+		"last_check >= 1439640000": true,
+		"active = 1":               true,
+		"groups >= linux":          true,
+		"groups >= windows":        false,
+		"groups < windows":         true,
+		"groups <= LINUX":          true,
+		"groups > LINUX":           false,
+		"groups > windows":         true,
+		"groups ~ ^lin":            true,
+		"parents =":                true,
+		"parents !=":               false,
+		"groups =":                 false,
+		"missing = 1":              false,
+	}
+
+	for data, expected := range tests {
+		f, err := ParseFilterRule(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := f.Match(r); result != expected {
+			t.Logf("\nExpected %t for %q\nbut got  %t\n", expected, data, result)
+			t.Fail()
+		}
+
+		// Rules built without parsing match the same
+		f = FilterRule{Column: f.Column, Op: f.Op, Value: f.Value}
+		if result := f.Match(r); result != expected {
+			t.Logf("\nExpected %t for unparsed %q\nbut got  %t\n", expected, data, result)
+			t.Fail()
+		}
+	}
+}
+
+func Test_FilterRuleRegexpCache(t *testing.T) {
+	f := FilterRule{Column: "name", Op: "~", Value: "^db[0-9]"}
+	if !f.Match(Record{"name": "db1"}) || f.regexp() != f.regexp() {
+		t.Logf("\nExpected the compiled expression to be reused\n")
+		t.Fail()
+	}
+
+	// Invalid expressions never match
+	f = FilterRule{Column: "name", Op: "~", Value: "("}
+	if f.Match(Record{"name": "("}) {
+		t.Logf("\nExpected no match for an invalid expression\n")
+		t.Fail()
+	}
+}
+
+func Test_FilterStack(t *testing.T) {
+	var s FilterStack
+	for _, rule := range []string{"state = 1", "state = 2", "acknowledged = 0"} {
+		f, err := ParseFilterRule(rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Push(f)
+	}
+
+	if err := s.Negate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.And(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Or(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Or(3); err == nil {
+		t.Log("\nExpected error combining more filters than available\n")
+		t.Fail()
+	}
+
+	f := s.Filter()
+	for r, expected := range map[*Record]bool{
+		&Record{"state": 1.0, "acknowledged": 0.0}: true,
+		&Record{"state": 2.0, "acknowledged": 1.0}: true,
+		&Record{"state": 2.0, "acknowledged": 0.0}: false,
+	} {
+		if result := f.Match(*r); result != expected {
+			t.Logf("\nExpected %t for %#v\nbut got  %t\n", expected, *r, result)
+			t.Fail()
+		}
+	}
+
+	expected := "GET hosts\n"
+	expected += "Filter: state = 1\n"
+	expected += "Filter: state = 2\n"
+	expected += "Filter: acknowledged = 0\n"
+	expected += "Negate:\n"
+	expected += "And: 2\n"
+	expected += "Or: 2\n"
+	expected += "ResponseHeader: fixed16\n"
+	expected += "OutputFormat: json\n\n"
+
+	q := newQuery("hosts", &Livestatus{}).Where(f)
+	if result := q.buildCmd(); result != expected {
+		t.Logf("\nExpected %q\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_QueryStatsWhere(t *testing.T) {
+	f := FilterOr{
+		FilterRule{Column: "state", Op: "=", Value: "1"},
+		FilterNot{FilterRule{Column: "acknowledged", Op: "=", Value: "0"}},
+	}
+
+	expected := "GET hosts\n"
+	expected += "Stats: state = 1\n"
+	expected += "Stats: acknowledged = 0\n"
+	expected += "StatsNegate:\n"
+	expected += "StatsOr: 2\n"
+	expected += "ResponseHeader: fixed16\n"
+	expected += "OutputFormat: json\n\n"
+
+	q := newQuery("hosts", &Livestatus{}).StatsWhere(f)
+	if result := q.buildCmd(); result != expected {
+		t.Logf("\nExpected %q\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+}
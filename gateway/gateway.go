@@ -0,0 +1,234 @@
+// Package gateway exposes a Livestatus instance over a REST/JSON HTTP API.
+//
+// Tables are queried with GET /tables/{table}, taking the optional query
+// parameters columns (comma separated), filter and stats (raw rules, which
+// may be repeated) and limit. The matching records are returned as a JSON
+// array of objects.
+//
+// External commands are sent with POST /commands/{name}, with a JSON object
+// of the named command arguments as the request body, such as
+//
+//	{"host_name": "db1", "sticky": true, "notify": false, "persistent": false,
+//	 "author": "admin", "comment": "on it"}
+//
+// for ACKNOWLEDGE_HOST_PROBLEM. Times may be given as seconds since the epoch
+// or in RFC3339 format, and durations as seconds or Go duration strings.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	lvst "github.com/tcolgate/go-livestatus"
+	"github.com/tcolgate/go-livestatus/nagios"
+)
+
+// Handler is an http.Handler serving the gateway API.
+type Handler struct {
+	// A Livestatus instance only handles one request at a time
+	sync.Mutex
+	ls *lvst.Livestatus
+}
+
+// New creates a new gateway handler for a Livestatus instance.
+func New(ls *lvst.Livestatus) *Handler {
+	return &Handler{ls: ls}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/tables/"):
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		h.serveTable(w, r, strings.TrimPrefix(r.URL.Path, "/tables/"))
+	case strings.HasPrefix(r.URL.Path, "/commands/"):
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		h.serveCommand(w, r, strings.TrimPrefix(r.URL.Path, "/commands/"))
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such resource %s", r.URL.Path))
+	}
+}
+
+func (h *Handler) serveTable(w http.ResponseWriter, r *http.Request, table string) {
+	if table == "" || strings.Contains(table, "/") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such table %q", table))
+		return
+	}
+
+	params := r.URL.Query()
+	for _, p := range []string{"columns", "filter", "stats"} {
+		if err := singleLine(p, params[p]...); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if err := singleLine("table", table); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var cols []string
+	for _, c := range params["columns"] {
+		cols = append(cols, strings.FieldsFunc(c, func(r rune) bool { return r == ',' || r == ' ' })...)
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	q := h.ls.Query(table)
+	if len(cols) > 0 {
+		q.Columns(cols...)
+	}
+	for _, f := range params["filter"] {
+		q.Filter(f)
+	}
+	for _, s := range params["stats"] {
+		q.Stats(s)
+	}
+	if l := params.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", l))
+			return
+		}
+		q.Limit(n)
+	}
+
+	resp, err := q.ExecContext(r.Context())
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	records := resp.Records
+	if records == nil {
+		records = []lvst.Record{}
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+func (h *Handler) serveCommand(w http.ResponseWriter, r *http.Request, name string) {
+	if _, ok := nagios.CommandArgs(name); !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown command %s", name))
+		return
+	}
+
+	var body map[string]interface{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid command arguments, %v", err))
+			return
+		}
+	}
+
+	args := map[string]string{}
+	for k, v := range body {
+		switch v := v.(type) {
+		case string:
+			if err := singleLine(k, v); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			args[k] = v
+		case bool:
+			args[k] = strconv.FormatBool(v)
+		case float64:
+			args[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid value for argument %s", k))
+			return
+		}
+	}
+
+	op, err := nagios.ParseCommand(name, args)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	c := h.ls.Command()
+	c.Op(op)
+	if _, err := c.ExecContext(r.Context()); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	// Livestatus gives no feedback on commands, so they can only be
+	// reported as accepted
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// singleLine checks the values of a parameter hold no line break, which
+// would be read by Livestatus as further headers or commands.
+func singleLine(name string, vals ...string) error {
+	for _, v := range vals {
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("invalid %s %q, line breaks are not allowed", name, v)
+		}
+	}
+	return nil
+}
+
+// httpStatus maps a Livestatus status code to an HTTP status code.
+func httpStatus(status int) int {
+	switch status {
+	case 200:
+		return http.StatusOK
+	case 400, 451, 452:
+		return http.StatusBadRequest
+	case 403:
+		return http.StatusForbidden
+	case 404:
+		return http.StatusNotFound
+	case 413:
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// statusClientClosedRequest reports requests cancelled by their client.
+const statusClientClosedRequest = 499
+
+// errorStatus returns the HTTP status code reporting a query error.
+func errorStatus(err error) int {
+	var serr *lvst.StatusError
+	var cerr *lvst.CircuitOpenError
+	switch {
+	case errors.As(err, &serr):
+		return httpStatus(serr.Status)
+	case errors.As(err, &cerr):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest
+	}
+	return http.StatusBadGateway
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,211 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+// fakeServer answers requests with a fixed status and body, recording the
+// requests it receives.
+type fakeServer struct {
+	sync.Mutex
+	status   int
+	body     string
+	requests []string
+}
+
+func newHandler(status int, body string) (*Handler, *fakeServer) {
+	s := &fakeServer{status: status, body: body}
+	ls := lvst.NewLivestatusWithDialer(func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go s.serve(server)
+		return client, nil
+	})
+	return New(ls), s
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	rd := bufio.NewReader(conn)
+	var req string
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			// Commands get no response, and are recorded once the
+			// connection is closed
+			s.record(req)
+			return
+		}
+		req += line
+		if line == "\n" {
+			s.record(req)
+			body := s.body + "\n"
+			fmt.Fprintf(conn, "%03d %11d\n%s", s.status, len(body), body)
+			return
+		}
+	}
+}
+
+func (s *fakeServer) record(req string) {
+	s.Lock()
+	s.requests = append(s.requests, req)
+	s.Unlock()
+}
+
+func (s *fakeServer) Requests() []string {
+	s.Lock()
+	defer s.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+func Test_GetTable(t *testing.T) {
+	h, srv := newHandler(200, `[["db1",0],["db2",2]]`)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/tables/hosts?columns=name,state&filter=state+>%3D+0&filter=name+~+db&limit=5", nil))
+
+	if w.Code != http.StatusOK {
+		t.Logf("\nExpected status %d\nbut got  %d\n", http.StatusOK, w.Code)
+		t.Fail()
+	}
+
+	expected := `[{"name":"db1","state":0},{"name":"db2","state":2}]` + "\n"
+	if result := w.Body.String(); result != expected {
+		t.Logf("\nExpected %q\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+
+	expectedReq := "GET hosts\n"
+	expectedReq += "Columns: name state\n"
+	expectedReq += "Filter: state >= 0\n"
+	expectedReq += "Filter: name ~ db\n"
+	expectedReq += "Limit: 5\n"
+	if reqs := srv.Requests(); len(reqs) != 1 || !strings.HasPrefix(reqs[0], expectedReq) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expectedReq, reqs)
+		t.Fail()
+	}
+}
+
+func Test_GetTableStatus(t *testing.T) {
+	tests := []struct {
+		status   int
+		body     string
+		path     string
+		expected int
+	}{
+		{404, "Invalid GET request, no such table 'nosuchtable'", "/tables/nosuchtable", http.StatusNotFound},
+		{400, "Invalid filter", "/tables/hosts?filter=bad", http.StatusBadRequest},
+		{452, "Completely invalid request", "/tables/hosts", http.StatusBadRequest},
+		{403, "Not authorized", "/tables/hosts", http.StatusForbidden},
+		{200, "[]", "/tables/hosts?limit=x", http.StatusBadRequest},
+		{200, "[]", "/nosuchpath", http.StatusNotFound},
+		{200, "[]", "/tables/hosts?filter=state+%3D+0%0AAuthUser:+admin", http.StatusBadRequest},
+		{200, "[]", "/tables/hosts?columns=name%0D%0AKeepAlive:+on", http.StatusBadRequest},
+		{200, "[]", "/tables/hosts?stats=state+%3D+0%0A", http.StatusBadRequest},
+		{200, "[]", "/tables/hosts%0AAuthUser:+admin", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		h, srv := newHandler(tt.status, tt.body)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.expected {
+			t.Logf("\nExpected status %d for %s\nbut got  %d\n", tt.expected, tt.path, w.Code)
+			t.Fail()
+		}
+		if tt.status == 200 && tt.expected != http.StatusOK && len(srv.Requests()) != 0 {
+			t.Logf("\nExpected no request for %s\nbut got  %q\n", tt.path, srv.Requests())
+			t.Fail()
+		}
+	}
+}
+
+func Test_ErrorStatus(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{&lvst.StatusError{Status: 404}, http.StatusNotFound},
+		{fmt.Errorf("retry failed, %w", &lvst.StatusError{Status: 403}), http.StatusForbidden},
+		{&lvst.CircuitOpenError{Backend: "site1", Err: errors.New("refused")}, http.StatusServiceUnavailable},
+		{fmt.Errorf("retry failed, %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{context.Canceled, statusClientClosedRequest},
+		{errors.New("connection refused"), http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		if result := errorStatus(tt.err); result != tt.expected {
+			t.Logf("\nExpected status %d for %v\nbut got  %d\n", tt.expected, tt.err, result)
+			t.Fail()
+		}
+	}
+}
+
+func Test_PostCommand(t *testing.T) {
+	h, srv := newHandler(200, "")
+
+	body := `{"host_name":"db1","sticky":true,"notify":false,"persistent":false,"author":"admin","comment":"on it"}`
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/commands/acknowledge_host_problem", strings.NewReader(body)))
+
+	if w.Code != http.StatusAccepted {
+		t.Logf("\nExpected status %d\nbut got  %d %s\n", http.StatusAccepted, w.Code, w.Body)
+		t.Fail()
+	}
+
+	// The command is sent on a connection kept alive, which is only closed
+	// once the handler is done with it
+	h.ls.Close()
+
+	expected := regexp.MustCompile(`^COMMAND \[[0-9]+\] ACKNOWLEDGE_HOST_PROBLEM;db1;2;0;0;admin;on it\n$`)
+	for i := 0; i < 100 && len(srv.Requests()) == 0; i++ {
+		<-time.After(time.Millisecond)
+	}
+	if reqs := srv.Requests(); len(reqs) != 1 || !expected.MatchString(reqs[0]) {
+		t.Logf("\nExpected %s\nbut got  %q\n", expected, reqs)
+		t.Fail()
+	}
+}
+
+func Test_PostCommandStatus(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"POST", "/commands/NO_SUCH_COMMAND", "{}", http.StatusNotFound},
+		{"POST", "/commands/ACKNOWLEDGE_HOST_PROBLEM", `{"host_name":"db1"}`, http.StatusBadRequest},
+		{"POST", "/commands/DEL_HOST_COMMENT", `{"comment_id":"abc"}`, http.StatusBadRequest},
+		{"POST", "/commands/DEL_HOST_COMMENT", `{"comment_id":[1]}`, http.StatusBadRequest},
+		{"POST", "/commands/DEL_HOST_COMMENT", `not json`, http.StatusBadRequest},
+		{"GET", "/commands/DEL_HOST_COMMENT", "", http.StatusMethodNotAllowed},
+		{"POST", "/commands/ACKNOWLEDGE_HOST_PROBLEM", `{"host_name":"db1","sticky":true,"notify":false,"persistent":false,"author":"admin","comment":"on it\nCOMMAND [0] SHUTDOWN_PROGRAM"}`, http.StatusBadRequest},
+		{"POST", "/commands/DEL_HOST_COMMENT", `{"comment_id":"1\r\n"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		h, _ := newHandler(200, "")
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if w.Code != tt.expected {
+			t.Logf("\nExpected status %d for %s %s\nbut got  %d\n", tt.expected, tt.path, tt.body, w.Code)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Package livestatustest provides a fake Livestatus instance for the tests
+// of the commands.
+package livestatustest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+// NewLivestatus returns an instance answering each query with the fixture
+// of its table, and queries on other tables with a 404 status. Fixtures are
+// response bodies, as JSON rows.
+func NewLivestatus(fixtures map[string]string) *lvst.Livestatus {
+	return lvst.NewLivestatusWithDialer(func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go serveFixture(server, fixtures)
+		return client, nil
+	})
+}
+
+func serveFixture(conn net.Conn, fixtures map[string]string) {
+	defer conn.Close()
+
+	table := ""
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "GET ") {
+			table = strings.TrimPrefix(line, "GET ")
+		}
+	}
+	if table == "" {
+		// Commands get no response
+		return
+	}
+
+	body, ok := fixtures[table]
+	status := 200
+	if !ok {
+		status = 404
+		body = "Invalid GET request, no such table"
+	}
+	body += "\n"
+
+	fmt.Fprintf(conn, "%03d %11d\n%s", status, len(body), body)
+}
@@ -1,7 +1,10 @@
 // Package livestatus provides a binding to MK Livestatus sockets.
 package livestatus
 
-import "net"
+import (
+	"net"
+	"sync"
+)
 
 // Livestatus is a binding instance.
 type Livestatus struct {
@@ -9,32 +12,80 @@ type Livestatus struct {
 	address string
 	dialer  func() (net.Conn, error)
 
+	// mu guards the connection kept alive, shared by the queries and
+	// commands of the instance.
+	mu        sync.Mutex
 	keepalive bool
 	keepConn  net.Conn
+
+	cache   *Cache
+	schema  *Schema
+	retry   *RetryPolicy
+	breaker *Breaker
+}
+
+// SetCache sets the cache queries are served from, nil disabling caching. A
+// cache may be shared by instances connected to the same site.
+func (l *Livestatus) SetCache(c *Cache) {
+	l.cache = c
 }
 
 // Close any open connection from a KeepAlive
 func (l *Livestatus) Close() error {
-	l.keepalive = false
-	if l.keepConn != nil {
-		l.keepConn = nil
-		return l.keepConn.Close()
+	l.mu.Lock()
+	conn := l.keepConn
+	l.keepalive, l.keepConn = false, nil
+	l.mu.Unlock()
+
+	if conn != nil {
+		return conn.Close()
 	}
 	return nil
 }
 
 // Query creates a new query instance on a spacific table.
 func (l *Livestatus) Query(table string) *Query {
-	l.keepalive = false
+	l.setKeepAlive(false)
 	return newQuery(table, l)
 }
 
 // Command creates a new command instanc.
 func (l *Livestatus) Command() *Command {
-	l.keepalive = true
+	l.setKeepAlive(true)
 	return newCommand(l)
 }
 
+func (l *Livestatus) setKeepAlive(on bool) {
+	l.mu.Lock()
+	l.keepalive = on
+	l.mu.Unlock()
+}
+
+// takeConn returns the connection kept alive, if any, for the sole use of
+// the caller, and whether the connection used should be kept alive.
+func (l *Livestatus) takeConn() (net.Conn, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	conn := l.keepConn
+	l.keepConn = nil
+	return conn, l.keepalive
+}
+
+// putConn keeps a connection alive for later use, closing it when another
+// one already is.
+func (l *Livestatus) putConn(conn net.Conn) {
+	l.mu.Lock()
+	if l.keepConn == nil {
+		l.keepConn = conn
+		conn = nil
+	}
+	l.mu.Unlock()
+
+	if conn != nil {
+		conn.Close()
+	}
+}
+
 // NewLivestatus creates a new binding instance.
 func NewLivestatus(network, address string) *Livestatus {
 	return &Livestatus{
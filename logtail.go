@@ -0,0 +1,507 @@
+package livestatus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Log entry classes, as found in the class column of the log table.
+const (
+	LogClassInfo         = 0
+	LogClassAlert        = 1
+	LogClassProgram      = 2
+	LogClassNotification = 3
+	LogClassPassive      = 4
+	LogClassCommand      = 5
+	LogClassState        = 6
+)
+
+var logColumns = []string{"time", "lineno", "class", "type", "options", "message"}
+
+var (
+	hostStates    = map[string]int64{"UP": 0, "DOWN": 1, "UNREACHABLE": 2}
+	serviceStates = map[string]int64{"OK": 0, "WARNING": 1, "CRITICAL": 2, "UNKNOWN": 3}
+)
+
+// LogEvent is a typed entry of the log table.
+type LogEvent interface {
+	Entry() LogEntry
+}
+
+// LogEntry is the common part of every log table entry. Entries of an
+// unrecognised type are reported as a plain LogEntry.
+type LogEntry struct {
+	Time    time.Time
+	LineNo  int64
+	Class   int64
+	Type    string
+	Message string
+}
+
+// Entry implements LogEvent.
+func (e LogEntry) Entry() LogEntry {
+	return e
+}
+
+// HostAlert is a host state change. Initial is set for the INITIAL and
+// CURRENT HOST STATE entries logged at startup and log rotation.
+type HostAlert struct {
+	LogEntry
+	Host      string
+	State     int64
+	StateType string
+	Attempt   int64
+	Output    string
+	Initial   bool
+}
+
+// ServiceAlert is a service state change. Initial is set for the INITIAL and
+// CURRENT SERVICE STATE entries logged at startup and log rotation.
+type ServiceAlert struct {
+	LogEntry
+	Host      string
+	Service   string
+	State     int64
+	StateType string
+	Attempt   int64
+	Output    string
+	Initial   bool
+}
+
+// Notification is a host or service notification sent to a contact. Service
+// is empty for host notifications. Reason holds the notified state, or the
+// kind of notification such as `ACKNOWLEDGEMENT (DOWN)`.
+type Notification struct {
+	LogEntry
+	Contact string
+	Host    string
+	Service string
+	Reason  string
+	Command string
+	Output  string
+}
+
+// DowntimeStart is logged when a host or service enters scheduled downtime.
+// Service is empty for host downtimes.
+type DowntimeStart struct {
+	LogEntry
+	Host    string
+	Service string
+	Comment string
+}
+
+// DowntimeStop is logged when a host or service leaves scheduled downtime,
+// either because it ended or was cancelled.
+type DowntimeStop struct {
+	LogEntry
+	Host      string
+	Service   string
+	Cancelled bool
+	Comment   string
+}
+
+// FlappingStart is logged when a host or service starts flapping.
+type FlappingStart struct {
+	LogEntry
+	Host    string
+	Service string
+	Comment string
+}
+
+// FlappingStop is logged when a host or service stops flapping, or flap
+// detection is disabled for it.
+type FlappingStop struct {
+	LogEntry
+	Host     string
+	Service  string
+	Disabled bool
+	Comment  string
+}
+
+// ExternalCommand is an external command received by the core.
+type ExternalCommand struct {
+	LogEntry
+	Command string
+	Args    []string
+}
+
+// ParseLogEvent converts a record of the log table into a typed event. The
+// record must hold the time, lineno, class, type, options and message
+// columns.
+func ParseLogEvent(r Record) (LogEvent, error) {
+	var (
+		e   LogEntry
+		err error
+	)
+
+	if e.Time, err = r.GetTime("time"); err != nil {
+		return nil, err
+	}
+	if e.LineNo, err = r.GetInt("lineno"); err != nil {
+		return nil, err
+	}
+	if e.Class, err = r.GetInt("class"); err != nil {
+		return nil, err
+	}
+	if e.Type, err = r.GetString("type"); err != nil {
+		return nil, err
+	}
+	if e.Message, err = r.GetString("message"); err != nil {
+		return nil, err
+	}
+	opts, err := r.GetString("options")
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Type {
+	case "HOST ALERT", "INITIAL HOST STATE", "CURRENT HOST STATE":
+		f := splitLogOptions(opts, 5)
+		if f == nil {
+			break
+		}
+		ev := HostAlert{LogEntry: e, Host: f[0], StateType: f[2], Output: f[4]}
+		ev.State = hostStates[f[1]]
+		ev.Attempt, _ = strconv.ParseInt(f[3], 10, 64)
+		ev.Initial = e.Type != "HOST ALERT"
+		return ev, nil
+
+	case "SERVICE ALERT", "INITIAL SERVICE STATE", "CURRENT SERVICE STATE":
+		f := splitLogOptions(opts, 6)
+		if f == nil {
+			break
+		}
+		ev := ServiceAlert{LogEntry: e, Host: f[0], Service: f[1], StateType: f[3], Output: f[5]}
+		ev.State = serviceStates[f[2]]
+		ev.Attempt, _ = strconv.ParseInt(f[4], 10, 64)
+		ev.Initial = e.Type != "SERVICE ALERT"
+		return ev, nil
+
+	case "HOST NOTIFICATION":
+		f := splitLogOptions(opts, 5)
+		if f == nil {
+			break
+		}
+		return Notification{LogEntry: e, Contact: f[0], Host: f[1], Reason: f[2], Command: f[3], Output: f[4]}, nil
+
+	case "SERVICE NOTIFICATION":
+		f := splitLogOptions(opts, 6)
+		if f == nil {
+			break
+		}
+		return Notification{LogEntry: e, Contact: f[0], Host: f[1], Service: f[2], Reason: f[3], Command: f[4], Output: f[5]}, nil
+
+	case "HOST DOWNTIME ALERT", "SERVICE DOWNTIME ALERT", "HOST FLAPPING ALERT", "SERVICE FLAPPING ALERT":
+		n := 3
+		if strings.HasPrefix(e.Type, "SERVICE") {
+			n = 4
+		}
+		f := splitLogOptions(opts, n)
+		if f == nil {
+			break
+		}
+		host, svc, action, comment := f[0], "", f[n-2], f[n-1]
+		if n == 4 {
+			svc = f[1]
+		}
+
+		if strings.Contains(e.Type, "DOWNTIME") {
+			switch action {
+			case "STARTED":
+				return DowntimeStart{LogEntry: e, Host: host, Service: svc, Comment: comment}, nil
+			case "STOPPED", "CANCELLED":
+				return DowntimeStop{LogEntry: e, Host: host, Service: svc, Cancelled: action == "CANCELLED", Comment: comment}, nil
+			}
+			break
+		}
+		switch action {
+		case "STARTED":
+			return FlappingStart{LogEntry: e, Host: host, Service: svc, Comment: comment}, nil
+		case "STOPPED", "DISABLED":
+			return FlappingStop{LogEntry: e, Host: host, Service: svc, Disabled: action == "DISABLED", Comment: comment}, nil
+		}
+
+	case "EXTERNAL COMMAND":
+		f := strings.Split(opts, ";")
+		return ExternalCommand{LogEntry: e, Command: f[0], Args: f[1:]}, nil
+	}
+
+	return e, nil
+}
+
+// splitLogOptions splits the options of a log entry into exactly n fields,
+// the last of which may contain semicolons. It returns nil if there are too
+// few fields.
+func splitLogOptions(opts string, n int) []string {
+	f := strings.SplitN(opts, ";", n)
+	if len(f) != n {
+		return nil
+	}
+	return f
+}
+
+// LogCheckpoint identifies the last log entry delivered by a LogTailer.
+type LogCheckpoint struct {
+	Time   int64 `json:"time"`
+	LineNo int64 `json:"lineno"`
+}
+
+// CheckpointStore persists the position of a LogTailer across restarts.
+// Load must return a zero checkpoint if none has been saved yet.
+type CheckpointStore interface {
+	Load() (LogCheckpoint, error)
+	Save(LogCheckpoint) error
+}
+
+// FileCheckpoint is a CheckpointStore saving checkpoints as JSON to a file.
+type FileCheckpoint string
+
+// Load implements CheckpointStore.
+func (f FileCheckpoint) Load() (LogCheckpoint, error) {
+	var cp LogCheckpoint
+
+	data, err := ioutil.ReadFile(string(f))
+	if os.IsNotExist(err) {
+		return cp, nil
+	} else if err != nil {
+		return cp, err
+	}
+
+	err = json.Unmarshal(data, &cp)
+	return cp, err
+}
+
+// Save implements CheckpointStore. The file is replaced atomically.
+func (f FileCheckpoint) Save(cp LogCheckpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(string(f)), filepath.Base(string(f)))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), string(f))
+}
+
+// LogTailer follows the log table, delivering new entries as typed events.
+type LogTailer struct {
+	ls       *Livestatus
+	classes  []int
+	filters  []string
+	since    time.Time
+	interval time.Duration
+	wait     bool
+	store    CheckpointStore
+	onError  func(error)
+}
+
+// LogTailer creates a new tailer of the log table. By default it polls every
+// 10 seconds for entries of any class logged after it started.
+func (l *Livestatus) LogTailer() *LogTailer {
+	return &LogTailer{
+		ls:       l,
+		interval: 10 * time.Second,
+	}
+}
+
+// Classes restricts the tailed entries to the given classes.
+func (t *LogTailer) Classes(classes ...int) *LogTailer {
+	t.classes = classes
+	return t
+}
+
+// Filter adds a filter on the tailed entries.
+func (t *LogTailer) Filter(rule string) *LogTailer {
+	t.filters = append(t.filters, rule)
+	return t
+}
+
+// Since sets the time from which to start tailing when no checkpoint has been
+// saved.
+func (t *LogTailer) Since(ts time.Time) *LogTailer {
+	t.since = ts
+	return t
+}
+
+// Interval sets the delay between polls, or the wait timeout when waiting on
+// the log trigger.
+func (t *LogTailer) Interval(d time.Duration) *LogTailer {
+	t.interval = d
+	return t
+}
+
+// WaitTrigger makes the tailer block on the log trigger rather than polling,
+// so that new entries are delivered as soon as they are logged.
+func (t *LogTailer) WaitTrigger(wait bool) *LogTailer {
+	t.wait = wait
+	return t
+}
+
+// Checkpoint sets the store the position of the tailer is loaded from and
+// saved to after every batch of delivered events.
+func (t *LogTailer) Checkpoint(store CheckpointStore) *LogTailer {
+	t.store = store
+	return t
+}
+
+// OnError sets a function called with the error of every failed query or
+// checkpoint save. Failed queries are otherwise retried silently.
+func (t *LogTailer) OnError(f func(error)) *LogTailer {
+	t.onError = f
+	return t
+}
+
+// Run starts tailing, delivering events on the returned channel until the
+// context is cancelled, at which point the channel is closed.
+func (t *LogTailer) Run(ctx context.Context) (<-chan LogEvent, error) {
+	var cp LogCheckpoint
+
+	if t.store != nil {
+		var err error
+		if cp, err = t.store.Load(); err != nil {
+			return nil, err
+		}
+	}
+	if cp.Time == 0 {
+		since := t.since
+		if since.IsZero() {
+			since = time.Now()
+		}
+		// Entries logged during the starting second are included, their line
+		// numbers starting at 1
+		cp = LogCheckpoint{Time: since.Unix(), LineNo: 0}
+	}
+
+	ch := make(chan LogEvent)
+	go t.run(ctx, cp, ch)
+
+	return ch, nil
+}
+
+func (t *LogTailer) run(ctx context.Context, cp LogCheckpoint, ch chan<- LogEvent) {
+	defer close(ch)
+
+	first := true
+	for ctx.Err() == nil {
+		// Polls wait before querying, except for the first
+		if !t.wait && !first {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(t.interval):
+			}
+		}
+
+		evs, err := t.query(ctx, cp, !first)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if t.onError != nil {
+				t.onError(err)
+			}
+			// Back off from a failing server when waiting
+			if t.wait {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(t.interval):
+				}
+			}
+			continue
+		}
+		first = false
+
+		if len(evs) == 0 {
+			continue
+		}
+		for _, ev := range evs {
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+			e := ev.Entry()
+			cp = LogCheckpoint{Time: e.Time.Unix(), LineNo: e.LineNo}
+		}
+
+		if t.store != nil {
+			if err := t.store.Save(cp); err != nil && t.onError != nil {
+				t.onError(err)
+			}
+		}
+	}
+}
+
+// query fetches the entries logged after the checkpoint, in log order.
+func (t *LogTailer) query(ctx context.Context, cp LogCheckpoint, wait bool) ([]LogEvent, error) {
+	q := t.ls.Query("log")
+	q.Columns(logColumns...)
+
+	q.Filter(fmt.Sprintf("time >= %d", cp.Time))
+	q.Filter(fmt.Sprintf("time > %d", cp.Time))
+	q.Filter(fmt.Sprintf("lineno > %d", cp.LineNo))
+	q.Or(2)
+
+	if len(t.classes) > 0 {
+		for _, c := range t.classes {
+			q.Filter(fmt.Sprintf("class = %d", c))
+		}
+		q.Or(len(t.classes))
+	}
+	for _, f := range t.filters {
+		q.Filter(f)
+	}
+
+	if t.wait && wait {
+		q.WaitTrigger("log")
+		q.WaitTimeout(t.interval)
+	}
+
+	resp, err := q.ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := resp.Records
+	sort.SliceStable(rs, func(i, j int) bool {
+		ti, _ := rs[i].GetInt("time")
+		tj, _ := rs[j].GetInt("time")
+		if ti != tj {
+			return ti < tj
+		}
+		li, _ := rs[i].GetInt("lineno")
+		lj, _ := rs[j].GetInt("lineno")
+		return li < lj
+	})
+
+	var evs []LogEvent
+	for _, r := range rs {
+		ev, err := ParseLogEvent(r)
+		if err != nil {
+			return nil, err
+		}
+		evs = append(evs, ev)
+	}
+	return evs, nil
+}
@@ -0,0 +1,254 @@
+package livestatus
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func logRecord(ts int64, lineno int64, class int64, typ, opts string) Record {
+	return Record{
+		"time":    float64(ts),
+		"lineno":  float64(lineno),
+		"class":   float64(class),
+		"type":    typ,
+		"options": opts,
+		"message": "[" + time.Unix(ts, 0).Format("15:04:05") + "] " + typ + ": " + opts,
+	}
+}
+
+func Test_ParseLogEvent(t *testing.T) {
+	tests := []struct {
+		record   Record
+		expected LogEvent
+	}{
+		{
+			logRecord(100, 1, 1, "HOST ALERT", "db1;DOWN;HARD;3;PING CRITICAL - Packet loss = 100%"),
+			HostAlert{Host: "db1", State: 1, StateType: "HARD", Attempt: 3, Output: "PING CRITICAL - Packet loss = 100%"},
+		},
+		{
+			logRecord(100, 2, 6, "CURRENT SERVICE STATE", "db1;Disk;WARNING;SOFT;1;DISK WARNING; /var 85%"),
+			ServiceAlert{Host: "db1", Service: "Disk", State: 1, StateType: "SOFT", Attempt: 1, Output: "DISK WARNING; /var 85%", Initial: true},
+		},
+		{
+			logRecord(100, 3, 3, "SERVICE NOTIFICATION", "admin;db1;Disk;ACKNOWLEDGEMENT (CRITICAL);notify-by-email;DISK CRITICAL"),
+			Notification{Contact: "admin", Host: "db1", Service: "Disk", Reason: "ACKNOWLEDGEMENT (CRITICAL)", Command: "notify-by-email", Output: "DISK CRITICAL"},
+		},
+		{
+			logRecord(100, 4, 1, "HOST DOWNTIME ALERT", "db1;STARTED;Host has entered a period of scheduled downtime"),
+			DowntimeStart{Host: "db1", Comment: "Host has entered a period of scheduled downtime"},
+		},
+		{
+			logRecord(100, 5, 1, "SERVICE DOWNTIME ALERT", "db1;Disk;CANCELLED;Scheduled downtime for service has been cancelled."),
+			DowntimeStop{Host: "db1", Service: "Disk", Cancelled: true, Comment: "Scheduled downtime for service has been cancelled."},
+		},
+		{
+			logRecord(100, 6, 1, "SERVICE FLAPPING ALERT", "db1;Load;STARTED;Service appears to have started flapping"),
+			FlappingStart{Host: "db1", Service: "Load", Comment: "Service appears to have started flapping"},
+		},
+		{
+			logRecord(100, 7, 1, "HOST FLAPPING ALERT", "db1;DISABLED;Flap detection has been disabled"),
+			FlappingStop{Host: "db1", Disabled: true, Comment: "Flap detection has been disabled"},
+		},
+		{
+			logRecord(100, 8, 5, "EXTERNAL COMMAND", "DEL_HOST_COMMENT;12"),
+			ExternalCommand{Command: "DEL_HOST_COMMENT", Args: []string{"12"}},
+		},
+		{
+			logRecord(100, 9, 2, "LOG VERSION", "2.0"),
+			LogEntry{},
+		},
+	}
+
+	for _, tt := range tests {
+		result, err := ParseLogEvent(tt.record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Fill in the common entry fields of the expected event
+		entry := LogEntry{
+			Time:    time.Unix(100, 0),
+			Class:   int64(tt.record["class"].(float64)),
+			LineNo:  int64(tt.record["lineno"].(float64)),
+			Type:    tt.record["type"].(string),
+			Message: tt.record["message"].(string),
+		}
+		expected := reflect.New(reflect.TypeOf(tt.expected)).Elem()
+		expected.Set(reflect.ValueOf(tt.expected))
+		if _, ok := tt.expected.(LogEntry); ok {
+			expected.Set(reflect.ValueOf(entry))
+		} else {
+			expected.FieldByName("LogEntry").Set(reflect.ValueOf(entry))
+		}
+
+		if !reflect.DeepEqual(result, expected.Interface()) {
+			t.Logf("\nExpected %#v\nbut got  %#v\n", expected.Interface(), result)
+			t.Fail()
+		}
+	}
+
+	if _, err := ParseLogEvent(Record{"time": 100.0}); err != ErrUnknownColumn {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrUnknownColumn, err)
+		t.Fail()
+	}
+}
+
+type memCheckpoint struct {
+	cp    LogCheckpoint
+	saves int
+}
+
+func (m *memCheckpoint) Load() (LogCheckpoint, error) {
+	return m.cp, nil
+}
+
+func (m *memCheckpoint) Save(cp LogCheckpoint) error {
+	m.cp = cp
+	m.saves++
+	return nil
+}
+
+func Test_LogTailer(t *testing.T) {
+	rows := []string{
+		`[[200,2,1,"HOST ALERT","db1;UP;HARD;1;PING OK","[200] HOST ALERT: db1;UP;HARD;1;PING OK"],` +
+			`[200,1,1,"HOST ALERT","db1;DOWN;HARD;1;PING CRITICAL","[200] HOST ALERT: db1;DOWN;HARD;1;PING CRITICAL"]]`,
+		`[]`,
+		`[[201,1,5,"EXTERNAL COMMAND","DEL_HOST_COMMENT;12","[201] EXTERNAL COMMAND: DEL_HOST_COMMENT;12"]]`,
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	n := 0
+	ls, srv := newFakeLivestatus(func(req string) (int, string) {
+		if n >= len(rows) {
+			<-done
+			return 200, "[]"
+		}
+		n++
+		return 200, rows[n-1]
+	})
+
+	store := &memCheckpoint{cp: LogCheckpoint{Time: 150, LineNo: 4}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := ls.LogTailer().
+		Classes(LogClassAlert, LogClassCommand).
+		Interval(time.Millisecond).
+		WaitTrigger(true).
+		Checkpoint(store).
+		Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result []string
+	for len(result) < 3 {
+		select {
+		case ev := <-ch:
+			e := ev.Entry()
+			result = append(result, strings.SplitN(e.Message, " ", 2)[1])
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for log events")
+		}
+	}
+
+	expected := []string{
+		"HOST ALERT: db1;DOWN;HARD;1;PING CRITICAL",
+		"HOST ALERT: db1;UP;HARD;1;PING OK",
+		"EXTERNAL COMMAND: DEL_HOST_COMMENT;12",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	cancel()
+	for range ch {
+	}
+
+	if store.cp != (LogCheckpoint{Time: 201, LineNo: 1}) || store.saves != 2 {
+		t.Logf("\nUnexpected checkpoint %#v after %d saves\n", store.cp, store.saves)
+		t.Fail()
+	}
+
+	queries := srv.Queries()
+
+	expectedQuery := "GET log\n"
+	expectedQuery += "Columns: time lineno class type options message\n"
+	expectedQuery += "Filter: time >= 150\n"
+	expectedQuery += "Filter: time > 150\n"
+	expectedQuery += "Filter: lineno > 4\n"
+	expectedQuery += "Or: 2\n"
+	expectedQuery += "Filter: class = 1\n"
+	expectedQuery += "Filter: class = 5\n"
+	expectedQuery += "Or: 2\n"
+	expectedQuery += "ResponseHeader: fixed16\n"
+	if !strings.HasPrefix(queries[0], expectedQuery) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expectedQuery, queries[0])
+		t.Fail()
+	}
+
+	if !strings.Contains(queries[1], "Filter: time >= 200\nFilter: time > 200\nFilter: lineno > 2\n") ||
+		!strings.Contains(queries[1], "WaitTrigger: log\nWaitTimeout: 1\n") {
+		t.Logf("\nUnexpected wait query %q\n", queries[1])
+		t.Fail()
+	}
+}
+
+func Test_LogTailerSince(t *testing.T) {
+	ls, srv := newFakeLivestatus(fixtureHandler(map[string]string{"log": "[]"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := ls.LogTailer().
+		Since(time.Unix(1439633040, 0)).
+		Interval(time.Hour).
+		Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for len(srv.Queries()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	for range ch {
+	}
+
+	expected := "Filter: time >= 1439633040\nFilter: time > 1439633040\nFilter: lineno > 0\nOr: 2\n"
+	if result := srv.Queries()[0]; !strings.Contains(result, expected) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_FileCheckpoint(t *testing.T) {
+	store := FileCheckpoint(filepath.Join(t.TempDir(), "checkpoint.json"))
+
+	cp, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	} else if cp != (LogCheckpoint{}) {
+		t.Logf("\nExpected empty checkpoint\nbut got  %#v\n", cp)
+		t.Fail()
+	}
+
+	expected := LogCheckpoint{Time: 1439633040, LineNo: 42}
+	if err = store.Save(expected); err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err = store.Load()
+	if err != nil {
+		t.Fatal(err)
+	} else if cp != expected {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, cp)
+		t.Fail()
+	}
+}
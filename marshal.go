@@ -0,0 +1,188 @@
+package livestatus
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryHeaders are the headers accepted by ParseQuery, and whether their
+// value is a number.
+var queryHeaders = map[string]bool{
+	"Columns": false, "Filter": false, "And": true, "Or": true, "Negate": false,
+	"Stats": false, "StatsAnd": true, "StatsOr": true, "StatsNegate": false,
+	"Limit": true, "AuthUser": false, "KeepAlive": false,
+	"WaitObject": false, "WaitCondition": false, "WaitConditionAnd": true,
+	"WaitConditionOr": true, "WaitConditionNegate": false, "WaitTrigger": false,
+	"WaitTimeout": true,
+}
+
+// ignoredQueryHeaders are the headers dropped by ParseQuery.
+var ignoredQueryHeaders = map[string]bool{
+	"ResponseHeader": true, "OutputFormat": true,
+	"ColumnHeaders": true, "Localtime": true, "Timelimit": true,
+}
+
+// queryDoc is the structured form of a query, as marshalled to JSON or YAML.
+type queryDoc struct {
+	Table   string   `json:"table" yaml:"table"`
+	Headers []string `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+// ParseQuery parses the text of a Livestatus query, a GET line followed by
+// header lines. The ResponseHeader and OutputFormat headers are ignored, as
+// they are set when executing the query, as are the unsupported
+// ColumnHeaders, Localtime and Timelimit headers. The query must be bound to
+// a Livestatus instance before being executed.
+func ParseQuery(s string) (*Query, error) {
+	q := newQuery("", nil)
+	if err := q.parseText(s); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// ParseQuery parses the text of a Livestatus query, bound to the instance.
+func (l *Livestatus) ParseQuery(s string) (*Query, error) {
+	q, err := ParseQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	return q.Bind(l), nil
+}
+
+// Bind sets the Livestatus instance executing the query. As with KeepAlive,
+// queries keeping the connection alive enable keepalive on the instance.
+func (q *Query) Bind(l *Livestatus) *Query {
+	q.ls = l
+	for _, h := range q.headers {
+		if h == "KeepAlive: on" {
+			l.setKeepAlive(true)
+		}
+	}
+	return q
+}
+
+// String returns the text of the query, as sent to Livestatus without the
+// headers set when executing it.
+func (q *Query) String() string {
+	s := "GET " + q.table + "\n"
+	for _, h := range q.headers {
+		s += h + "\n"
+	}
+	return s
+}
+
+// MarshalText returns the text of the query.
+func (q *Query) MarshalText() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// UnmarshalText parses the text of a query, which must then be bound to a
+// Livestatus instance.
+func (q *Query) UnmarshalText(b []byte) error {
+	return q.parseText(string(b))
+}
+
+// MarshalJSON encodes the query as an object holding its table and headers.
+func (q *Query) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.doc())
+}
+
+// UnmarshalJSON decodes a query encoded by MarshalJSON.
+func (q *Query) UnmarshalJSON(b []byte) error {
+	var d queryDoc
+	if err := json.Unmarshal(b, &d); err != nil {
+		return err
+	}
+	return q.fromDoc(d)
+}
+
+// MarshalYAML encodes the query as a mapping holding its table and headers.
+func (q *Query) MarshalYAML() (interface{}, error) {
+	return q.doc(), nil
+}
+
+// UnmarshalYAML decodes a query encoded by MarshalYAML.
+func (q *Query) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var d queryDoc
+	if err := unmarshal(&d); err != nil {
+		return err
+	}
+	return q.fromDoc(d)
+}
+
+func (q *Query) doc() queryDoc {
+	return queryDoc{Table: q.table, Headers: q.headers}
+}
+
+func (q *Query) fromDoc(d queryDoc) error {
+	lines := append([]string{"GET " + d.Table}, d.Headers...)
+	return q.parseText(strings.Join(lines, "\n"))
+}
+
+// parseText resets the query to the one described by a query text.
+func (q *Query) parseText(s string) error {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if !strings.HasPrefix(lines[0], "GET ") {
+		return fmt.Errorf("invalid query, expected a GET line, got %q", lines[0])
+	}
+	table := strings.TrimSpace(strings.TrimPrefix(lines[0], "GET "))
+	if table == "" {
+		return fmt.Errorf("invalid query, no table")
+	}
+
+	p := newQuery(table, q.ls)
+	for _, line := range lines[1:] {
+		line = strings.TrimRight(line, "\r")
+		i := strings.Index(line, ":")
+		if i == -1 {
+			return fmt.Errorf("invalid query header %q", line)
+		}
+		name, value := line[:i], strings.TrimSpace(line[i+1:])
+
+		numeric, ok := queryHeaders[name]
+		switch {
+		case ignoredQueryHeaders[name]:
+			continue
+		case !ok:
+			return fmt.Errorf("unsupported query header %s", name)
+		case numeric:
+			if _, err := strconv.Atoi(value); err != nil {
+				return fmt.Errorf("invalid query header %q", line)
+			}
+		}
+
+		// Headers with state of their own go through their setters
+		n, _ := strconv.Atoi(value)
+		switch name {
+		case "Columns":
+			p.Columns(strings.Fields(value)...)
+		case "Stats":
+			p.Stats(value)
+		case "WaitObject":
+			p.WaitObject(value)
+		case "WaitCondition":
+			p.WaitCondition(value)
+		case "WaitConditionAnd":
+			p.WaitConditionAnd(n)
+		case "WaitConditionOr":
+			p.WaitConditionOr(n)
+		case "WaitConditionNegate":
+			p.WaitConditionNegate()
+		case "WaitTrigger":
+			p.WaitTrigger(value)
+		case "WaitTimeout":
+			p.WaitTimeout(time.Duration(n) * time.Millisecond)
+		case "Negate", "StatsNegate":
+			p.headers = append(p.headers, name+":")
+		default:
+			p.headers = append(p.headers, name+": "+value)
+		}
+	}
+
+	*q = *p
+	return nil
+}
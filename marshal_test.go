@@ -0,0 +1,193 @@
+package livestatus
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_ParseQuery(t *testing.T) {
+	text := "GET services\n" +
+		"Columns: host_name description\n" +
+		"Filter: state != 0\n" +
+		"Filter: acknowledged = 0\n" +
+		"And: 2\n" +
+		"Negate:\n" +
+		"Limit: 10\n"
+
+	q, err := ParseQuery(text + "ResponseHeader: fixed16\nOutputFormat: python\n" +
+		"ColumnHeaders: on\nLocaltime: 1439633040\nTimelimit: 5\n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result := q.String(); result != text {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", text, result)
+		t.Fail()
+	}
+
+	expected := []string{"host_name", "description"}
+	if !reflect.DeepEqual(q.columns, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, q.columns)
+		t.Fail()
+	}
+
+	for _, data := range []string{
+		"",
+		"GET \n",
+		"COMMAND [0] DISABLE_NOTIFICATIONS\n",
+		"GET hosts\nFilter state = 0\n",
+		"GET hosts\nLimit: ten\n",
+		"GET hosts\nResponseFormat: json\n",
+	} {
+		if _, err := ParseQuery(data); err == nil {
+			t.Logf("\nExpected error for %q\n", data)
+			t.Fail()
+		}
+	}
+}
+
+func Test_QueryBindKeepAlive(t *testing.T) {
+	ls := NewLivestatus("tcp", "localhost:6557")
+	ls.keepalive = true
+
+	q, err := ParseQuery("GET hosts\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Bind(ls)
+	if !ls.keepalive {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", true, ls.keepalive)
+		t.Fail()
+	}
+
+	ls = NewLivestatus("tcp", "localhost:6557")
+	if q, err = ParseQuery("GET hosts\nKeepAlive: on\n"); err != nil {
+		t.Fatal(err)
+	}
+	q.Bind(ls)
+	if !ls.keepalive {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", true, ls.keepalive)
+		t.Fail()
+	}
+}
+
+func Test_ParseQueryWaitCache(t *testing.T) {
+	ls, s := newFakeLivestatus(fixtureHandler(map[string]string{
+		"hosts": `[["name"],["db1"]]`,
+	}))
+	ls.SetCache(NewCache(time.Minute))
+
+	for _, text := range []string{
+		"GET hosts\nWaitObject: db1\n",
+		"GET hosts\nWaitTrigger: check\n",
+		"GET hosts\nWaitTimeout: 1000\n",
+		"GET hosts\nWaitCondition: state = 0\nWaitCondition: state = 1\nWaitConditionOr: 2\nWaitConditionNegate:\n",
+	} {
+		q, err := ls.ParseQuery(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := q.String(); result != text {
+			t.Logf("\nExpected %#v\nbut got  %#v\n", text, result)
+			t.Fail()
+		}
+
+		before := len(s.Queries())
+		for i := 0; i < 2; i++ {
+			if _, err := q.Exec(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if n := len(s.Queries()) - before; n != 2 {
+			t.Logf("\nExpected %q to bypass the cache\nbut got  %d queries\n", text, n)
+			t.Fail()
+		}
+	}
+}
+
+func Test_ParseQueryExec(t *testing.T) {
+	ls, s := newFakeLivestatus(fixtureHandler(map[string]string{
+		"hosts": `[[2]]`,
+	}))
+
+	q, err := ParseQuery("GET hosts\nStats: state = 0\nStats: state != 0\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := q.Exec(); err != ErrUnboundQuery {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrUnboundQuery, err)
+		t.Fail()
+	}
+
+	resp, err := q.Bind(ls).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Record{Record{"stats_1": 2.0}}
+	if !reflect.DeepEqual(resp.Records, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Records)
+		t.Fail()
+	}
+
+	expectedQuery := "GET hosts\nStats: state = 0\nStats: state != 0\nResponseHeader: fixed16\nOutputFormat: json\n\n"
+	if result := s.Queries(); len(result) != 1 || result[0] != expectedQuery {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", []string{expectedQuery}, result)
+		t.Fail()
+	}
+}
+
+func Test_QueryMarshalJSON(t *testing.T) {
+	q := newQuery("hosts", &Livestatus{}).Columns("name", "state").Filter("state = 1")
+
+	b, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"table":"hosts","headers":["Columns: name state","Filter: state = 1"]}`
+	if string(b) != expected {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, string(b))
+		t.Fail()
+	}
+
+	var result Query
+	if err := json.Unmarshal(b, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != q.String() {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", q.String(), result.String())
+		t.Fail()
+	}
+
+	if err := json.Unmarshal([]byte(`{"table":"hosts","headers":["Bogus: 1"]}`), &result); err == nil {
+		t.Logf("\nExpected error for an unsupported header\n")
+		t.Fail()
+	}
+}
+
+func Test_QueryMarshalYAML(t *testing.T) {
+	q := newQuery("hosts", &Livestatus{}).Columns("name").Limit(5)
+
+	doc, err := q.MarshalYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// YAML libraries decode into the value given to unmarshal
+	var result Query
+	err = result.UnmarshalYAML(func(v interface{}) error {
+		reflect.ValueOf(v).Elem().Set(reflect.ValueOf(doc))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != q.String() {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", q.String(), result.String())
+		t.Fail()
+	}
+}
@@ -30,6 +30,15 @@ type nagCmd struct {
 
 var nagCmds = []nagCmd{}
 
+// extraCmds are the commands for which the nagios documentation gives no
+// usable definition.
+var extraCmds = []nagCmd{
+	{
+		def:  "SCHEDULE_SVC_DOWNTIME;<host_name>;<service_description>;<start_time>;<end_time>;<fixed>;<trigger_id>;<duration>;<author>;<comment>",
+		desc: "Schedules downtime for a specified service.  If the \"fixed\" argument is set to one (1), downtime will start and end at the times specified by the \"start\" and \"end\" arguments.  Otherwise, downtime will begin between the \"start\" and \"end\" times and last for \"duration\" seconds.  The \"start\" and \"end\" arguments are specified in time_t format (seconds since the UNIX epoch).  The specified service downtime can be triggered by another downtime entry if the \"trigger_id\" is set to the ID of another scheduled downtime entry.  Set the \"trigger_id\" argument to zero (0) if the downtime for the specified service should not be triggered by another downtime entry.",
+	},
+}
+
 type argDef struct {
 	t      string
 	fmtStr string
@@ -124,7 +133,7 @@ func main() {
 		nagCmds = append(nagCmds, nagCmd{def, desc, "", nil})
 	}
 
-	genCode(file, "nagios", nagCmds)
+	genCode(file, "nagios", append(nagCmds, extraCmds...))
 }
 
 func findDefAndDesc(n *html.Node) (string, string) {
@@ -172,6 +181,7 @@ func genCode(w io.Writer, pkg string, cmds []nagCmd) {
 	fmt.Fprintf(w, "import lvst \"github.com/tcolgate/go-livestatus\"\n\n")
 
 	done := map[string]bool{}
+	gen := []nagCmd{}
 	for _, c := range cmds {
 		err := c.parseCommandDef()
 		if err != nil {
@@ -203,7 +213,19 @@ func genCode(w io.Writer, pkg string, cmds []nagCmd) {
 		fmt.Fprint(w, "\t}\n")
 		fmt.Fprint(w, "}\n\n")
 		done[c.name] = true
+		gen = append(gen, c)
+	}
+
+	fmt.Fprintln(w, "// commandArgs lists the argument names of each generated command, in order.")
+	fmt.Fprintln(w, "var commandArgs = map[string][]string{")
+	for _, c := range gen {
+		args := []string{}
+		for _, a := range c.args {
+			args = append(args, fmt.Sprintf("%q", a))
+		}
+		fmt.Fprintf(w, "\t%q: []string{%s},\n", c.name, strings.Join(args, ", "))
 	}
+	fmt.Fprintln(w, "}")
 }
 
 var cmdTmpl = regexp.MustCompile("^[A-Z_]+$")
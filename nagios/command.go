@@ -2,6 +2,9 @@ package nagios
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	lvst "github.com/tcolgate/go-livestatus"
@@ -79,12 +82,16 @@ func Delete(c *lvst.Command, b bool) {
 	c.Arg(normalBool(b).String())
 }
 
+// textEscaper keeps free text, such as comments, on the command line, as
+// Nagios has no way to escape line breaks within it.
+var textEscaper = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
 func Author(c *lvst.Command, s string) {
-	c.Arg(s)
+	c.Arg(textEscaper.Replace(s))
 }
 
 func Comment(c *lvst.Command, s string) {
-	c.Arg(s)
+	c.Arg(textEscaper.Replace(s))
 }
 
 func Start(c *lvst.Command, t time.Time) {
@@ -111,6 +118,12 @@ func Duration(c *lvst.Command, t time.Duration) {
 	c.Arg(stringDuration{t}.String())
 }
 
+// CheckInterval sets a check interval, in interval units of a minute by
+// default, unlike durations.
+func CheckInterval(c *lvst.Command, f float64) {
+	c.Arg(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
 func TriggerID(c *lvst.Command, i int) {
 	c.Arg(i)
 }
@@ -178,3 +191,188 @@ func FileName(c *lvst.Command, s string) {
 func PluginOutput(c *lvst.Command, s string) {
 	c.Arg(s)
 }
+
+// argParsers converts the textual form of each named command argument,
+// mirroring the argument types used by the command generator.
+var argParsers = map[string]func(*lvst.Command, string) error{
+	"host_name":               stringArg(Hostname),
+	"service_description":     stringArg(ServiceDescription),
+	"sticky":                  boolArg(Sticky),
+	"notify":                  boolArg(Notify),
+	"fixed":                   boolArg(Fixed),
+	"persistent":              boolArg(Persistent),
+	"author":                  stringArg(Author),
+	"contact_name":            stringArg(ContactName),
+	"contactgroup_name":       stringArg(ContactGroupName),
+	"hostgroup_name":          stringArg(HostGroupName),
+	"servicegroup_name":       stringArg(ServiceGroupName),
+	"comment":                 stringArg(Comment),
+	"start_time":              timeArg(Start),
+	"end_time":                timeArg(End),
+	"check_time":              timeArg(CheckTime),
+	"notification_time":       timeArg(NotificationTime),
+	"notification_timeperiod": stringArg(NotificationTimePeriod),
+	"duration":                durationArg(Duration),
+	"trigger_id":              intArg(TriggerID),
+	"downtime_id":             intArg(DowntimeID),
+	"comment_id":              intArg(CommentID),
+	"options":                 intArg(Options),
+	"value":                   stringArg(Value),
+	"varname":                 stringArg(VarName),
+	"varvalue":                stringArg(VarValue),
+	"event_handler_command":   stringArg(EventHandlerCommand),
+	"check_command":           stringArg(CheckCommand),
+	"timeperiod":              stringArg(TimePeriod),
+	"check_timeperod":         stringArg(CheckTimePeriod),
+	"check_timeperiod":        stringArg(CheckTimePeriod),
+	"check_attempts":          intArg(CheckAttempts),
+	"check_interval":          floatArg(CheckInterval),
+	"file_name":               stringArg(FileName),
+	"delete":                  boolArg(Delete),
+	"status_code":             intArg(StatusCode),
+	"return_code":             intArg(ReturnCode),
+	"plugin_output":           stringArg(PluginOutput),
+	"notification_number":     intArg(NotificationNumber),
+}
+
+func stringArg(f func(*lvst.Command, string)) func(*lvst.Command, string) error {
+	return func(c *lvst.Command, s string) error {
+		f(c, s)
+		return nil
+	}
+}
+
+func boolArg(f func(*lvst.Command, bool)) func(*lvst.Command, string) error {
+	return func(c *lvst.Command, s string) error {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f(c, b)
+		return nil
+	}
+}
+
+func intArg(f func(*lvst.Command, int)) func(*lvst.Command, string) error {
+	return func(c *lvst.Command, s string) error {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		f(c, i)
+		return nil
+	}
+}
+
+func floatArg(f func(*lvst.Command, float64)) func(*lvst.Command, string) error {
+	return func(c *lvst.Command, s string) error {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		f(c, v)
+		return nil
+	}
+}
+
+// timeArg accepts either seconds since the epoch or an RFC3339 time.
+func timeArg(f func(*lvst.Command, time.Time)) func(*lvst.Command, string) error {
+	return func(c *lvst.Command, s string) error {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			f(c, time.Unix(i, 0))
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		f(c, t)
+		return nil
+	}
+}
+
+// durationArg accepts either a number of seconds or a Go duration string.
+func durationArg(f func(*lvst.Command, time.Duration)) func(*lvst.Command, string) error {
+	return func(c *lvst.Command, s string) error {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			f(c, time.Duration(i)*time.Second)
+			return nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f(c, d)
+		return nil
+	}
+}
+
+// CommandNames returns the names of all the known external commands, sorted.
+func CommandNames() []string {
+	var names []string
+	for n := range commandArgs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CommandArgs returns the argument names of the named external command, in
+// the order the command expects them.
+func CommandArgs(name string) ([]string, bool) {
+	args, ok := commandArgs[strings.ToUpper(name)]
+	return args, ok
+}
+
+// ParseCommand builds the named external command from textual named
+// arguments, as given on a command line. Every argument of the command must be
+// provided. Booleans are parsed with strconv.ParseBool, times may be given as
+// seconds since the epoch or in RFC3339 format, durations as seconds or in
+// time.ParseDuration format, and check intervals as a number of interval
+// units.
+func ParseCommand(name string, args map[string]string) (lvst.CommandOpFunc, error) {
+	name = strings.ToUpper(name)
+	argNames, ok := commandArgs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown command %s", name)
+	}
+
+	for a := range args {
+		found := false
+		for _, n := range argNames {
+			found = found || n == a
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown argument %s for command %s", a, name)
+		}
+	}
+
+	var ops []func(*lvst.Command) error
+	for _, n := range argNames {
+		v, ok := args[n]
+		if !ok {
+			return nil, fmt.Errorf("missing argument %s for command %s", n, name)
+		}
+		parse := argParsers[n]
+		ops = append(ops, func(c *lvst.Command) error { return parse(c, v) })
+	}
+
+	// Validate the arguments up front so the returned op can't fail
+	if err := buildOps(&lvst.Command{}, ops); err != nil {
+		return nil, fmt.Errorf("invalid arguments for command %s, %v", name, err)
+	}
+
+	return func(c *lvst.Command) {
+		c.Raw(name)
+		buildOps(c, ops)
+	}, nil
+}
+
+func buildOps(c *lvst.Command, ops []func(*lvst.Command) error) error {
+	for _, op := range ops {
+		if err := op(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
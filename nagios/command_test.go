@@ -0,0 +1,122 @@
+package nagios
+
+import (
+	"bufio"
+	"net"
+	"regexp"
+	"testing"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+// sent executes the command op against a pipe and returns the line written.
+func sent(t *testing.T, op lvst.CommandOpFunc) string {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	ls := lvst.NewLivestatusWithDialer(func() (net.Conn, error) { return client, nil })
+	defer ls.Close()
+
+	res := make(chan string)
+	go func() {
+		line, _ := bufio.NewReader(server).ReadString('\n')
+		res <- line
+	}()
+
+	c := ls.Command()
+	c.Op(op)
+	if _, err := c.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	return <-res
+}
+
+func Test_CommandArgs(t *testing.T) {
+	args, ok := CommandArgs("schedule_host_downtime")
+	if !ok {
+		t.Fatal("expected SCHEDULE_HOST_DOWNTIME to be known")
+	}
+	if len(args) != 8 || args[0] != "host_name" || args[6] != "author" {
+		t.Logf("\nUnexpected arguments %#v\n", args)
+		t.Fail()
+	}
+}
+
+func Test_ParseCommand(t *testing.T) {
+	args := map[string]string{
+		"host_name":           "db1",
+		"service_description": "Disk",
+		"sticky":              "true",
+		"notify":              "false",
+		"persistent":          "1",
+		"author":              "admin",
+		"comment":             "on it",
+	}
+
+	op, err := ParseCommand("ACKNOWLEDGE_SVC_PROBLEM", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := regexp.MustCompile(`^COMMAND \[[0-9]+\] ACKNOWLEDGE_SVC_PROBLEM;db1;Disk;2;0;1;admin;on it\n$`)
+	if result := sent(t, op); !expected.MatchString(result) {
+		t.Logf("\nExpected %s\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+
+	op, err = ParseCommand("SCHEDULE_HOST_DOWNTIME", map[string]string{
+		"host_name":  "db1",
+		"start_time": "1439633040",
+		"end_time":   "2015-08-15T11:04:00Z",
+		"fixed":      "true",
+		"trigger_id": "0",
+		"duration":   "2h",
+		"author":     "admin",
+		"comment":    "upgrade",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = regexp.MustCompile(`^COMMAND \[[0-9]+\] SCHEDULE_HOST_DOWNTIME;db1;1439633040;1439636640;1;0;7200;admin;upgrade\n$`)
+	if result := sent(t, op); !expected.MatchString(result) {
+		t.Logf("\nExpected %s\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+
+	op, err = ParseCommand("CHANGE_NORMAL_SVC_CHECK_INTERVAL", map[string]string{
+		"host_name":           "db1",
+		"service_description": "Disk",
+		"check_interval":      "2.5",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = regexp.MustCompile(`^COMMAND \[[0-9]+\] CHANGE_NORMAL_SVC_CHECK_INTERVAL;db1;Disk;2.5\n$`)
+	if result := sent(t, op); !expected.MatchString(result) {
+		t.Logf("\nExpected %s\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+
+	tests := map[string]map[string]string{
+		"NO_SUCH_COMMAND":  {},
+		"DEL_HOST_COMMENT": {},
+		"DEL_SVC_COMMENT":  {"comment_id": "abc"},
+		"CHANGE_NORMAL_HOST_CHECK_INTERVAL": {
+			"host_name":      "db1",
+			"check_interval": "5m",
+		},
+		"ENABLE_HOST_CHECK": {
+			"host_name": "db1",
+			"bogus":     "1",
+		},
+	}
+
+	for name, args := range tests {
+		if _, err := ParseCommand(name, args); err == nil {
+			t.Logf("\nExpected error for %s %v\n", name, args)
+			t.Fail()
+		}
+	}
+}
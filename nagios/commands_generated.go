@@ -1831,3 +1831,181 @@ func StopObsessingOverSvcChecks() lvst.CommandOpFunc{
 	}
 }
 
+// ScheduleSvcDowntime is generated from the nagios external command definition:
+// Desfinition: SCHEDULE_SVC_DOWNTIME;<host_name>;<service_description>;<start_time>;<end_time>;<fixed>;<trigger_id>;<duration>;<author>;<comment>
+// Description:
+//  Schedules downtime for a specified service.  If the "fixed" argument is set to one (1), downtime will start and end at the times specified by the "start" and "end" arguments.  Otherwise, downtime will begin between the "start" and "end" times and last for "duration" seconds.  The "start" and "end" arguments are specified in time_t format (seconds since the UNIX epoch).  The specified service downtime can be triggered by another downtime entry if the "trigger_id" is set to the ID of another scheduled downtime entry.  Set the "trigger_id" argument to zero (0) if the downtime for the specified service should not be triggered by another downtime entry.
+func ScheduleSvcDowntime(host_name string, service_description string, start_time time.Time, end_time time.Time, fixed bool, trigger_id int, duration time.Duration, author string, comment string) lvst.CommandOpFunc{
+	return func (c *lvst.Command) {
+		c.Raw("SCHEDULE_SVC_DOWNTIME")
+		Hostname(c, host_name)
+		ServiceDescription(c, service_description)
+		Start(c, start_time)
+		End(c, end_time)
+		Fixed(c, fixed)
+		TriggerID(c, trigger_id)
+		Duration(c, duration)
+		Author(c, author)
+		Comment(c, comment)
+	}
+}
+
+// commandArgs lists the argument names of each generated command, in order.
+var commandArgs = map[string][]string{
+	"ACKNOWLEDGE_HOST_PROBLEM": []string{"host_name", "sticky", "notify", "persistent", "author", "comment"},
+	"ACKNOWLEDGE_SVC_PROBLEM": []string{"host_name", "service_description", "sticky", "notify", "persistent", "author", "comment"},
+	"ADD_HOST_COMMENT": []string{"host_name", "persistent", "author", "comment"},
+	"ADD_SVC_COMMENT": []string{"host_name", "service_description", "persistent", "author", "comment"},
+	"CHANGE_CONTACT_HOST_NOTIFICATION_TIMEPERIOD": []string{"contact_name", "notification_timeperiod"},
+	"CHANGE_CONTACT_MODATTR": []string{"contact_name", "value"},
+	"CHANGE_CONTACT_MODHATTR": []string{"contact_name", "value"},
+	"CHANGE_CONTACT_MODSATTR": []string{"contact_name", "value"},
+	"CHANGE_CONTACT_SVC_NOTIFICATION_TIMEPERIOD": []string{"contact_name", "notification_timeperiod"},
+	"CHANGE_CUSTOM_CONTACT_VAR": []string{"contact_name", "varname", "varvalue"},
+	"CHANGE_CUSTOM_HOST_VAR": []string{"host_name", "varname", "varvalue"},
+	"CHANGE_CUSTOM_SVC_VAR": []string{"host_name", "service_description", "varname", "varvalue"},
+	"CHANGE_GLOBAL_HOST_EVENT_HANDLER": []string{"event_handler_command"},
+	"CHANGE_GLOBAL_SVC_EVENT_HANDLER": []string{"event_handler_command"},
+	"CHANGE_HOST_CHECK_COMMAND": []string{"host_name", "check_command"},
+	"CHANGE_HOST_CHECK_TIMEPERIOD": []string{"host_name", "timeperiod"},
+	"CHANGE_HOST_EVENT_HANDLER": []string{"host_name", "event_handler_command"},
+	"CHANGE_HOST_MODATTR": []string{"host_name", "value"},
+	"CHANGE_MAX_HOST_CHECK_ATTEMPTS": []string{"host_name", "check_attempts"},
+	"CHANGE_MAX_SVC_CHECK_ATTEMPTS": []string{"host_name", "service_description", "check_attempts"},
+	"CHANGE_NORMAL_HOST_CHECK_INTERVAL": []string{"host_name", "check_interval"},
+	"CHANGE_NORMAL_SVC_CHECK_INTERVAL": []string{"host_name", "service_description", "check_interval"},
+	"CHANGE_RETRY_HOST_CHECK_INTERVAL": []string{"host_name", "service_description", "check_interval"},
+	"CHANGE_RETRY_SVC_CHECK_INTERVAL": []string{"host_name", "service_description", "check_interval"},
+	"CHANGE_SVC_CHECK_COMMAND": []string{"host_name", "service_description", "check_command"},
+	"CHANGE_SVC_CHECK_TIMEPERIOD": []string{"host_name", "service_description", "check_timeperiod"},
+	"CHANGE_SVC_EVENT_HANDLER": []string{"host_name", "service_description", "event_handler_command"},
+	"CHANGE_SVC_MODATTR": []string{"host_name", "service_description", "value"},
+	"CHANGE_SVC_NOTIFICATION_TIMEPERIOD": []string{"host_name", "service_description", "notification_timeperiod"},
+	"DELAY_HOST_NOTIFICATION": []string{"host_name", "notification_time"},
+	"DELAY_SVC_NOTIFICATION": []string{"host_name", "service_description", "notification_time"},
+	"DEL_ALL_HOST_COMMENTS": []string{"host_name"},
+	"DEL_ALL_SVC_COMMENTS": []string{"host_name", "service_description"},
+	"DEL_HOST_COMMENT": []string{"comment_id"},
+	"DEL_HOST_DOWNTIME": []string{"downtime_id"},
+	"DEL_SVC_COMMENT": []string{"comment_id"},
+	"DEL_SVC_DOWNTIME": []string{"downtime_id"},
+	"DISABLE_ALL_NOTIFICATIONS_BEYOND_HOST": []string{"host_name"},
+	"DISABLE_CONTACTGROUP_HOST_NOTIFICATIONS": []string{"contactgroup_name"},
+	"DISABLE_CONTACTGROUP_SVC_NOTIFICATIONS": []string{"contactgroup_name"},
+	"DISABLE_CONTACT_HOST_NOTIFICATIONS": []string{"contact_name"},
+	"DISABLE_CONTACT_SVC_NOTIFICATIONS": []string{"contact_name"},
+	"DISABLE_EVENT_HANDLERS": []string{},
+	"DISABLE_FAILURE_PREDICTION": []string{},
+	"DISABLE_FLAP_DETECTION": []string{},
+	"DISABLE_HOSTGROUP_HOST_CHECKS": []string{"hostgroup_name"},
+	"DISABLE_HOSTGROUP_HOST_NOTIFICATIONS": []string{"hostgroup_name"},
+	"DISABLE_HOSTGROUP_PASSIVE_HOST_CHECKS": []string{"hostgroup_name"},
+	"DISABLE_HOSTGROUP_PASSIVE_SVC_CHECKS": []string{"hostgroup_name"},
+	"DISABLE_HOSTGROUP_SVC_CHECKS": []string{"hostgroup_name"},
+	"DISABLE_HOSTGROUP_SVC_NOTIFICATIONS": []string{"hostgroup_name"},
+	"DISABLE_HOST_AND_CHILD_NOTIFICATIONS": []string{"host_name"},
+	"DISABLE_HOST_CHECK": []string{"host_name"},
+	"DISABLE_HOST_EVENT_HANDLER": []string{"host_name"},
+	"DISABLE_HOST_FLAP_DETECTION": []string{"host_name"},
+	"DISABLE_HOST_FRESHNESS_CHECKS": []string{},
+	"DISABLE_HOST_NOTIFICATIONS": []string{"host_name"},
+	"DISABLE_HOST_SVC_CHECKS": []string{"host_name"},
+	"DISABLE_HOST_SVC_NOTIFICATIONS": []string{"host_name"},
+	"DISABLE_NOTIFICATIONS": []string{},
+	"DISABLE_PASSIVE_HOST_CHECKS": []string{"host_name"},
+	"DISABLE_PASSIVE_SVC_CHECKS": []string{"host_name", "service_description"},
+	"DISABLE_PERFORMANCE_DATA": []string{},
+	"DISABLE_SERVICEGROUP_HOST_CHECKS": []string{"servicegroup_name"},
+	"DISABLE_SERVICEGROUP_HOST_NOTIFICATIONS": []string{"servicegroup_name"},
+	"DISABLE_SERVICEGROUP_PASSIVE_HOST_CHECKS": []string{"servicegroup_name"},
+	"DISABLE_SERVICEGROUP_PASSIVE_SVC_CHECKS": []string{"servicegroup_name"},
+	"DISABLE_SERVICEGROUP_SVC_CHECKS": []string{"servicegroup_name"},
+	"DISABLE_SERVICEGROUP_SVC_NOTIFICATIONS": []string{"servicegroup_name"},
+	"DISABLE_SERVICE_FLAP_DETECTION": []string{"host_name", "service_description"},
+	"DISABLE_SERVICE_FRESHNESS_CHECKS": []string{},
+	"DISABLE_SVC_CHECK": []string{"host_name", "service_description"},
+	"DISABLE_SVC_EVENT_HANDLER": []string{"host_name", "service_description"},
+	"DISABLE_SVC_FLAP_DETECTION": []string{"host_name", "service_description"},
+	"DISABLE_SVC_NOTIFICATIONS": []string{"host_name", "service_description"},
+	"ENABLE_ALL_NOTIFICATIONS_BEYOND_HOST": []string{"host_name"},
+	"ENABLE_CONTACTGROUP_HOST_NOTIFICATIONS": []string{"contactgroup_name"},
+	"ENABLE_CONTACTGROUP_SVC_NOTIFICATIONS": []string{"contactgroup_name"},
+	"ENABLE_CONTACT_HOST_NOTIFICATIONS": []string{"contact_name"},
+	"ENABLE_CONTACT_SVC_NOTIFICATIONS": []string{"contact_name"},
+	"ENABLE_EVENT_HANDLERS": []string{},
+	"ENABLE_FAILURE_PREDICTION": []string{},
+	"ENABLE_FLAP_DETECTION": []string{},
+	"ENABLE_HOSTGROUP_HOST_CHECKS": []string{"hostgroup_name"},
+	"ENABLE_HOSTGROUP_HOST_NOTIFICATIONS": []string{"hostgroup_name"},
+	"ENABLE_HOSTGROUP_PASSIVE_HOST_CHECKS": []string{"hostgroup_name"},
+	"ENABLE_HOSTGROUP_PASSIVE_SVC_CHECKS": []string{"hostgroup_name"},
+	"ENABLE_HOSTGROUP_SVC_CHECKS": []string{"hostgroup_name"},
+	"ENABLE_HOSTGROUP_SVC_NOTIFICATIONS": []string{"hostgroup_name"},
+	"ENABLE_HOST_AND_CHILD_NOTIFICATIONS": []string{"host_name"},
+	"ENABLE_HOST_CHECK": []string{"host_name"},
+	"ENABLE_HOST_EVENT_HANDLER": []string{"host_name"},
+	"ENABLE_HOST_FLAP_DETECTION": []string{"host_name"},
+	"ENABLE_HOST_FRESHNESS_CHECKS": []string{},
+	"ENABLE_HOST_NOTIFICATIONS": []string{"host_name"},
+	"ENABLE_HOST_SVC_CHECKS": []string{"host_name"},
+	"ENABLE_HOST_SVC_NOTIFICATIONS": []string{"host_name"},
+	"ENABLE_NOTIFICATIONS": []string{},
+	"ENABLE_PASSIVE_HOST_CHECKS": []string{"host_name"},
+	"ENABLE_PASSIVE_SVC_CHECKS": []string{"host_name", "service_description"},
+	"ENABLE_PERFORMANCE_DATA": []string{},
+	"ENABLE_SERVICEGROUP_HOST_CHECKS": []string{"servicegroup_name"},
+	"ENABLE_SERVICEGROUP_HOST_NOTIFICATIONS": []string{"servicegroup_name"},
+	"ENABLE_SERVICEGROUP_PASSIVE_HOST_CHECKS": []string{"servicegroup_name"},
+	"ENABLE_SERVICEGROUP_PASSIVE_SVC_CHECKS": []string{"servicegroup_name"},
+	"ENABLE_SERVICEGROUP_SVC_CHECKS": []string{"servicegroup_name"},
+	"ENABLE_SERVICEGROUP_SVC_NOTIFICATIONS": []string{"servicegroup_name"},
+	"ENABLE_SERVICE_FRESHNESS_CHECKS": []string{},
+	"ENABLE_SVC_CHECK": []string{"host_name", "service_description"},
+	"ENABLE_SVC_EVENT_HANDLER": []string{"host_name", "service_description"},
+	"ENABLE_SVC_FLAP_DETECTION": []string{"host_name", "service_description"},
+	"ENABLE_SVC_NOTIFICATIONS": []string{"host_name", "service_description"},
+	"PROCESS_FILE": []string{"file_name", "delete"},
+	"PROCESS_HOST_CHECK_RESULT": []string{"host_name", "status_code", "plugin_output"},
+	"PROCESS_SERVICE_CHECK_RESULT": []string{"host_name", "service_description", "return_code", "plugin_output"},
+	"READ_STATE_INFORMATION": []string{},
+	"REMOVE_HOST_ACKNOWLEDGEMENT": []string{"host_name"},
+	"REMOVE_SVC_ACKNOWLEDGEMENT": []string{"host_name", "service_description"},
+	"RESTART_PROGRAM": []string{},
+	"SAVE_STATE_INFORMATION": []string{},
+	"SCHEDULE_AND_PROPAGATE_HOST_DOWNTIME": []string{"host_name", "start_time", "end_time", "fixed", "trigger_id", "duration", "author", "comment"},
+	"SCHEDULE_AND_PROPAGATE_TRIGGERED_HOST_DOWNTIME": []string{"host_name", "start_time", "end_time", "fixed", "trigger_id", "duration", "author", "comment"},
+	"SCHEDULE_FORCED_HOST_CHECK": []string{"host_name", "check_time"},
+	"SCHEDULE_FORCED_HOST_SVC_CHECKS": []string{"host_name", "check_time"},
+	"SCHEDULE_FORCED_SVC_CHECK": []string{"host_name", "service_description", "check_time"},
+	"SCHEDULE_HOSTGROUP_HOST_DOWNTIME": []string{"hostgroup_name", "start_time", "end_time", "fixed", "trigger_id", "duration", "author", "comment"},
+	"SCHEDULE_HOSTGROUP_SVC_DOWNTIME": []string{"hostgroup_name", "start_time", "end_time", "fixed", "trigger_id", "duration", "author", "comment"},
+	"SCHEDULE_HOST_CHECK": []string{"host_name", "check_time"},
+	"SCHEDULE_HOST_DOWNTIME": []string{"host_name", "start_time", "end_time", "fixed", "trigger_id", "duration", "author", "comment"},
+	"SCHEDULE_HOST_SVC_CHECKS": []string{"host_name", "check_time"},
+	"SCHEDULE_HOST_SVC_DOWNTIME": []string{"host_name", "start_time", "end_time", "fixed", "trigger_id", "duration", "author", "comment"},
+	"SCHEDULE_SERVICEGROUP_HOST_DOWNTIME": []string{"servicegroup_name", "start_time", "end_time", "fixed", "trigger_id", "duration", "author", "comment"},
+	"SCHEDULE_SERVICEGROUP_SVC_DOWNTIME": []string{"servicegroup_name", "start_time", "end_time", "fixed", "trigger_id", "duration", "author", "comment"},
+	"SCHEDULE_SVC_CHECK": []string{"host_name", "service_description", "check_time"},
+	"SEND_CUSTOM_HOST_NOTIFICATION": []string{"host_name", "options", "author", "comment"},
+	"SEND_CUSTOM_SVC_NOTIFICATION": []string{"host_name", "service_description", "options", "author", "comment"},
+	"SET_HOST_NOTIFICATION_NUMBER": []string{"host_name", "notification_number"},
+	"SET_SVC_NOTIFICATION_NUMBER": []string{"host_name", "service_description", "notification_number"},
+	"SHUTDOWN_PROGRAM": []string{},
+	"START_ACCEPTING_PASSIVE_HOST_CHECKS": []string{},
+	"START_ACCEPTING_PASSIVE_SVC_CHECKS": []string{},
+	"START_EXECUTING_HOST_CHECKS": []string{},
+	"START_EXECUTING_SVC_CHECKS": []string{},
+	"START_OBSESSING_OVER_HOST": []string{"host_name"},
+	"START_OBSESSING_OVER_HOST_CHECKS": []string{},
+	"START_OBSESSING_OVER_SVC": []string{"host_name", "service_description"},
+	"START_OBSESSING_OVER_SVC_CHECKS": []string{},
+	"STOP_ACCEPTING_PASSIVE_HOST_CHECKS": []string{},
+	"STOP_ACCEPTING_PASSIVE_SVC_CHECKS": []string{},
+	"STOP_EXECUTING_HOST_CHECKS": []string{},
+	"STOP_EXECUTING_SVC_CHECKS": []string{},
+	"STOP_OBSESSING_OVER_HOST": []string{"host_name"},
+	"STOP_OBSESSING_OVER_HOST_CHECKS": []string{},
+	"STOP_OBSESSING_OVER_SVC": []string{"host_name", "service_description"},
+	"STOP_OBSESSING_OVER_SVC_CHECKS": []string{},
+	"SCHEDULE_SVC_DOWNTIME": []string{"host_name", "service_description", "start_time", "end_time", "fixed", "trigger_id", "duration", "author", "comment"},
+}
@@ -0,0 +1,312 @@
+package nagios
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+// Comment entry types, as found in the entry_type column of the comments
+// table.
+const (
+	CommentUser            = 1
+	CommentDowntime        = 2
+	CommentFlapping        = 3
+	CommentAcknowledgement = 4
+)
+
+var commentColumns = []string{
+	"id", "host_name", "service_description", "is_service", "author", "comment",
+	"entry_time", "entry_type", "persistent", "expires", "expire_time",
+}
+
+// CommentEntry is an entry of the comments table. Service is empty for host
+// comments.
+type CommentEntry struct {
+	ID         int
+	Host       string
+	Service    string
+	Author     string
+	Comment    string
+	Entry      time.Time
+	EntryType  int
+	Persistent bool
+	Expires    bool
+	Expire     time.Time
+}
+
+// Object identifies a host, or a service when Service is set.
+type Object struct {
+	Host    string
+	Service string
+}
+
+// AckOptions are the options of an acknowledgement.
+type AckOptions struct {
+	Sticky     bool
+	Notify     bool
+	Persistent bool
+	Author     string
+	Comment    string
+}
+
+// Comments manages the comments of a Livestatus instance.
+type Comments struct {
+	ls  *lvst.Livestatus
+	now func() time.Time
+}
+
+// NewComments creates a new comment manager.
+func NewComments(ls *lvst.Livestatus) *Comments {
+	return &Comments{ls: ls, now: time.Now}
+}
+
+// List returns the comments matching the filters, or all comments if none are
+// given.
+func (c *Comments) List(ctx context.Context, filters ...string) ([]CommentEntry, error) {
+	q := c.ls.Query("comments").Columns(commentColumns...)
+	for _, f := range filters {
+		q.Filter(f)
+	}
+
+	resp, err := q.ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]CommentEntry, len(resp.Records))
+	for i, r := range resp.Records {
+		if res[i], err = decodeComment(r); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Add adds a comment to a host, or to a service if service is not empty.
+func (c *Comments) Add(ctx context.Context, obj Object, persistent bool, author, comment string) error {
+	op := AddHostComment(obj.Host, persistent, author, comment)
+	if obj.Service != "" {
+		op = AddSvcComment(obj.Host, obj.Service, persistent, author, comment)
+	}
+	return send(ctx, c.ls, op)
+}
+
+// Delete deletes the given comments.
+func (c *Comments) Delete(ctx context.Context, cs ...CommentEntry) error {
+	for _, cm := range cs {
+		op := DelHostComment(cm.ID)
+		if cm.Service != "" {
+			op = DelSvcComment(cm.ID)
+		}
+		if err := send(ctx, c.ls, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteByAuthor deletes the user comments of an author, returning the
+// deleted comments.
+func (c *Comments) DeleteByAuthor(ctx context.Context, author string) ([]CommentEntry, error) {
+	cs, err := c.List(ctx, "author = "+author, fmt.Sprintf("entry_type = %d", CommentUser))
+	if err != nil {
+		return nil, err
+	}
+	return cs, c.Delete(ctx, cs...)
+}
+
+// DeleteOlderThan deletes the user comments entered more than age ago,
+// returning the deleted comments.
+func (c *Comments) DeleteOlderThan(ctx context.Context, age time.Duration) ([]CommentEntry, error) {
+	cs, err := c.List(ctx,
+		fmt.Sprintf("entry_time < %d", c.now().Add(-age).Unix()),
+		fmt.Sprintf("entry_type = %d", CommentUser))
+	if err != nil {
+		return nil, err
+	}
+	return cs, c.Delete(ctx, cs...)
+}
+
+// Acks manages the acknowledgements of host and service problems.
+type Acks struct {
+	ls       *lvst.Livestatus
+	comments *Comments
+}
+
+// NewAcks creates a new acknowledgement manager.
+func NewAcks(ls *lvst.Livestatus) *Acks {
+	return &Acks{ls: ls, comments: NewComments(ls)}
+}
+
+// Acknowledge acknowledges the current problem of a host or service.
+func (a *Acks) Acknowledge(ctx context.Context, obj Object, opts AckOptions) error {
+	op := AcknowledgeHostProblem(obj.Host, opts.Sticky, opts.Notify, opts.Persistent, opts.Author, opts.Comment)
+	if obj.Service != "" {
+		op = AcknowledgeSvcProblem(obj.Host, obj.Service, opts.Sticky, opts.Notify, opts.Persistent, opts.Author, opts.Comment)
+	}
+	return send(ctx, a.ls, op)
+}
+
+// AcknowledgeHostProblems acknowledges all the unacknowledged host problems
+// matching the filters, returning the acknowledged hosts.
+func (a *Acks) AcknowledgeHostProblems(ctx context.Context, opts AckOptions, filters ...string) ([]Object, error) {
+	return a.acknowledgeProblems(ctx, "hosts", []string{"name"}, opts, filters)
+}
+
+// AcknowledgeServiceProblems acknowledges all the unacknowledged service
+// problems matching the filters, returning the acknowledged services.
+func (a *Acks) AcknowledgeServiceProblems(ctx context.Context, opts AckOptions, filters ...string) ([]Object, error) {
+	return a.acknowledgeProblems(ctx, "services", []string{"host_name", "description"}, opts, filters)
+}
+
+func (a *Acks) acknowledgeProblems(ctx context.Context, table string, cols []string, opts AckOptions, filters []string) ([]Object, error) {
+	q := a.ls.Query(table).Columns(cols...)
+	q.Filter("state != 0")
+	q.Filter("acknowledged = 0")
+	for _, f := range filters {
+		q.Filter(f)
+	}
+
+	resp, err := q.ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []Object
+	for _, r := range resp.Records {
+		var obj Object
+		if obj.Host, err = r.GetString(cols[0]); err != nil {
+			return nil, err
+		}
+		if len(cols) > 1 {
+			if obj.Service, err = r.GetString(cols[1]); err != nil {
+				return nil, err
+			}
+		}
+		objs = append(objs, obj)
+	}
+
+	for i, obj := range objs {
+		if err := a.Acknowledge(ctx, obj, opts); err != nil {
+			return objs[:i], err
+		}
+	}
+	return objs, nil
+}
+
+// List returns the acknowledgement comments matching the filters.
+func (a *Acks) List(ctx context.Context, filters ...string) ([]CommentEntry, error) {
+	return a.comments.List(ctx, append([]string{fmt.Sprintf("entry_type = %d", CommentAcknowledgement)}, filters...)...)
+}
+
+// Remove removes the acknowledgement of a host or service problem.
+func (a *Acks) Remove(ctx context.Context, obj Object) error {
+	op := RemoveHostAcknowledgement(obj.Host)
+	if obj.Service != "" {
+		op = RemoveSvcAcknowledgement(obj.Host, obj.Service)
+	}
+	return send(ctx, a.ls, op)
+}
+
+// RemoveByAuthor removes the acknowledgements made by an author, returning
+// the objects no longer acknowledged.
+func (a *Acks) RemoveByAuthor(ctx context.Context, author string) ([]Object, error) {
+	cs, err := a.List(ctx, "author = "+author)
+	if err != nil {
+		return nil, err
+	}
+	return a.remove(ctx, cs)
+}
+
+// RemoveOlderThan removes the acknowledgements made more than age ago,
+// returning the objects no longer acknowledged.
+func (a *Acks) RemoveOlderThan(ctx context.Context, age time.Duration) ([]Object, error) {
+	cs, err := a.List(ctx, fmt.Sprintf("entry_time < %d", a.comments.now().Add(-age).Unix()))
+	if err != nil {
+		return nil, err
+	}
+	return a.remove(ctx, cs)
+}
+
+func (a *Acks) remove(ctx context.Context, cs []CommentEntry) ([]Object, error) {
+	var objs []Object
+
+	seen := map[Object]bool{}
+	for _, cm := range cs {
+		obj := Object{Host: cm.Host, Service: cm.Service}
+		if seen[obj] {
+			continue
+		}
+		seen[obj] = true
+
+		if err := a.Remove(ctx, obj); err != nil {
+			return objs, err
+		}
+		objs = append(objs, obj)
+	}
+
+	return objs, nil
+}
+
+// send executes a single command.
+func send(ctx context.Context, ls *lvst.Livestatus, op lvst.CommandOpFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c := ls.Command()
+	c.Op(op)
+	_, err := c.ExecContext(ctx)
+	return err
+}
+
+func decodeComment(r lvst.Record) (CommentEntry, error) {
+	var (
+		cm  CommentEntry
+		err error
+		n   int64
+	)
+
+	if n, err = r.GetInt("id"); err != nil {
+		return cm, err
+	}
+	cm.ID = int(n)
+	if cm.Host, err = r.GetString("host_name"); err != nil {
+		return cm, err
+	}
+	isService, err := r.GetBool("is_service")
+	if err != nil {
+		return cm, err
+	}
+	if isService {
+		if cm.Service, err = r.GetString("service_description"); err != nil {
+			return cm, err
+		}
+	}
+	if cm.Author, err = r.GetString("author"); err != nil {
+		return cm, err
+	}
+	if cm.Comment, err = r.GetString("comment"); err != nil {
+		return cm, err
+	}
+	if cm.Entry, err = r.GetTime("entry_time"); err != nil {
+		return cm, err
+	}
+	if n, err = r.GetInt("entry_type"); err != nil {
+		return cm, err
+	}
+	cm.EntryType = int(n)
+	if cm.Persistent, err = r.GetBool("persistent"); err != nil {
+		return cm, err
+	}
+	if cm.Expires, err = r.GetBool("expires"); err != nil {
+		return cm, err
+	}
+	if cm.Expire, err = r.GetTime("expire_time"); err != nil {
+		return cm, err
+	}
+
+	return cm, nil
+}
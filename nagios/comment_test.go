@@ -0,0 +1,182 @@
+package nagios
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_CommentsList(t *testing.T) {
+	ls, srv := newFakeLivestatus(func(req string) (int, string) {
+		return 200, `[[7,"db1","Disk",1,"admin","disk replaced",1439630000,1,1,0,0],[8,"db1","",0,"admin","ack",1439630100,4,0,0,0]]`
+	})
+
+	result, err := NewComments(ls).List(context.Background(), "host_name = db1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []CommentEntry{
+		CommentEntry{
+			ID:         7,
+			Host:       "db1",
+			Service:    "Disk",
+			Author:     "admin",
+			Comment:    "disk replaced",
+			Entry:      time.Unix(1439630000, 0),
+			EntryType:  CommentUser,
+			Persistent: true,
+			Expire:     time.Unix(0, 0),
+		},
+		CommentEntry{
+			ID:        8,
+			Host:      "db1",
+			Author:    "admin",
+			Comment:   "ack",
+			Entry:     time.Unix(1439630100, 0),
+			EntryType: CommentAcknowledgement,
+			Expire:    time.Unix(0, 0),
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	expectedQuery := "GET comments\n"
+	expectedQuery += "Columns: " + strings.Join(commentColumns, " ") + "\n"
+	expectedQuery += "Filter: host_name = db1\n"
+	if q := srv.Queries()[0]; !strings.HasPrefix(q, expectedQuery) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expectedQuery, q)
+		t.Fail()
+	}
+}
+
+func Test_CommentsDeleteOlderThan(t *testing.T) {
+	ls, srv := newFakeLivestatus(func(req string) (int, string) {
+		return 200, `[[7,"db1","Disk",1,"admin","old",1439630000,1,1,0,0],[9,"db2","",0,"admin","old",1439630000,1,1,0,0]]`
+	})
+
+	c := NewComments(ls)
+	c.now = func() time.Time { return time.Unix(1439640000, 0) }
+
+	result, err := c.DeleteOlderThan(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 {
+		t.Logf("\nExpected 2 deleted comments\nbut got  %#v\n", result)
+		t.Fail()
+	}
+
+	expectedQuery := "GET comments\n"
+	expectedQuery += "Columns: " + strings.Join(commentColumns, " ") + "\n"
+	expectedQuery += "Filter: entry_time < 1439636400\n"
+	expectedQuery += "Filter: entry_type = 1\n"
+	if q := srv.Queries()[0]; !strings.HasPrefix(q, expectedQuery) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expectedQuery, q)
+		t.Fail()
+	}
+
+	expectedCmds := []*regexp.Regexp{
+		regexp.MustCompile(`^COMMAND \[[0-9]+\] DEL_SVC_COMMENT;7$`),
+		regexp.MustCompile(`^COMMAND \[[0-9]+\] DEL_HOST_COMMENT;9$`),
+	}
+	cmds := srv.WaitCommands(len(expectedCmds))
+	if len(cmds) != len(expectedCmds) {
+		t.Fatalf("\nExpected %d commands\nbut got  %q\n", len(expectedCmds), cmds)
+	}
+	for i, re := range expectedCmds {
+		if !re.MatchString(cmds[i]) {
+			t.Logf("\nExpected %s\nbut got  %q\n", re, cmds[i])
+			t.Fail()
+		}
+	}
+}
+
+func Test_AcksAcknowledgeServiceProblems(t *testing.T) {
+	ls, srv := newFakeLivestatus(func(req string) (int, string) {
+		return 200, `[["db1","Disk"],["db2","Load"]]`
+	})
+
+	opts := AckOptions{Sticky: true, Notify: true, Author: "admin", Comment: "on it"}
+	result, err := NewAcks(ls).AcknowledgeServiceProblems(context.Background(), opts, "host_groups >= db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Object{{"db1", "Disk"}, {"db2", "Load"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	expectedQuery := "GET services\n"
+	expectedQuery += "Columns: host_name description\n"
+	expectedQuery += "Filter: state != 0\n"
+	expectedQuery += "Filter: acknowledged = 0\n"
+	expectedQuery += "Filter: host_groups >= db\n"
+	if q := srv.Queries()[0]; !strings.HasPrefix(q, expectedQuery) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expectedQuery, q)
+		t.Fail()
+	}
+
+	expectedCmds := []*regexp.Regexp{
+		regexp.MustCompile(`^COMMAND \[[0-9]+\] ACKNOWLEDGE_SVC_PROBLEM;db1;Disk;2;1;0;admin;on it$`),
+		regexp.MustCompile(`^COMMAND \[[0-9]+\] ACKNOWLEDGE_SVC_PROBLEM;db2;Load;2;1;0;admin;on it$`),
+	}
+	cmds := srv.WaitCommands(len(expectedCmds))
+	if len(cmds) != len(expectedCmds) {
+		t.Fatalf("\nExpected %d commands\nbut got  %q\n", len(expectedCmds), cmds)
+	}
+	for i, re := range expectedCmds {
+		if !re.MatchString(cmds[i]) {
+			t.Logf("\nExpected %s\nbut got  %q\n", re, cmds[i])
+			t.Fail()
+		}
+	}
+}
+
+func Test_AcksRemoveByAuthor(t *testing.T) {
+	ls, srv := newFakeLivestatus(func(req string) (int, string) {
+		return 200, `[[8,"db1","",0,"bob","ack",1439630100,4,0,0,0],[10,"db1","",0,"bob","ack",1439630200,4,0,0,0],[11,"db1","Disk",1,"bob","ack",1439630300,4,0,0,0]]`
+	})
+
+	result, err := NewAcks(ls).RemoveByAuthor(context.Background(), "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Object{{Host: "db1"}, {"db1", "Disk"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	expectedQuery := "GET comments\n"
+	expectedQuery += "Columns: " + strings.Join(commentColumns, " ") + "\n"
+	expectedQuery += "Filter: entry_type = 4\n"
+	expectedQuery += "Filter: author = bob\n"
+	if q := srv.Queries()[0]; !strings.HasPrefix(q, expectedQuery) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expectedQuery, q)
+		t.Fail()
+	}
+
+	expectedCmds := []*regexp.Regexp{
+		regexp.MustCompile(`^COMMAND \[[0-9]+\] REMOVE_HOST_ACKNOWLEDGEMENT;db1$`),
+		regexp.MustCompile(`^COMMAND \[[0-9]+\] REMOVE_SVC_ACKNOWLEDGEMENT;db1;Disk$`),
+	}
+	cmds := srv.WaitCommands(len(expectedCmds))
+	if len(cmds) != len(expectedCmds) {
+		t.Fatalf("\nExpected %d commands\nbut got  %q\n", len(expectedCmds), cmds)
+	}
+	for i, re := range expectedCmds {
+		if !re.MatchString(cmds[i]) {
+			t.Logf("\nExpected %s\nbut got  %q\n", re, cmds[i])
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package nagios
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+// ErrDowntimeNotFound is returned when a scheduled downtime does not show up
+// in the downtimes table before the timeout.
+var ErrDowntimeNotFound = errors.New("scheduled downtime not found")
+
+var downtimeColumns = []string{
+	"id", "host_name", "service_description", "is_service", "author", "comment",
+	"start_time", "end_time", "entry_time", "fixed", "duration", "triggered_by",
+}
+
+// Downtime is an entry of the downtimes table. Service is empty for host
+// downtimes.
+type Downtime struct {
+	ID          int
+	Host        string
+	Service     string
+	Author      string
+	Comment     string
+	Start       time.Time
+	End         time.Time
+	Entry       time.Time
+	Fixed       bool
+	Duration    time.Duration
+	TriggeredBy int
+}
+
+// DowntimeSpec describes a downtime to schedule. Fixed downtimes last from
+// Start to End, others start when a problem occurs between Start and End and
+// last for Duration. TriggerID optionally names the downtime triggering this
+// one.
+type DowntimeSpec struct {
+	Start     time.Time
+	End       time.Time
+	Fixed     bool
+	Duration  time.Duration
+	TriggerID int
+	Author    string
+	Comment   string
+}
+
+// Downtimes manages the scheduled downtimes of a Livestatus instance.
+type Downtimes struct {
+	ls *lvst.Livestatus
+
+	// PollInterval is the delay between queries checking a scheduled
+	// downtime has been created.
+	PollInterval time.Duration
+	// Timeout is how long to wait for a scheduled downtime to be created.
+	Timeout time.Duration
+}
+
+// NewDowntimes creates a new downtime manager.
+func NewDowntimes(ls *lvst.Livestatus) *Downtimes {
+	return &Downtimes{
+		ls:           ls,
+		PollInterval: 500 * time.Millisecond,
+		Timeout:      10 * time.Second,
+	}
+}
+
+// ScheduleHost schedules a downtime for a host, returning the created
+// downtime.
+func (d *Downtimes) ScheduleHost(ctx context.Context, host string, spec DowntimeSpec) ([]Downtime, error) {
+	op := ScheduleHostDowntime(host, spec.Start, spec.End, spec.Fixed, spec.TriggerID, spec.Duration, spec.Author, spec.Comment)
+	return d.schedule(ctx, op, spec, 1, "host_name = "+host, "is_service = 0")
+}
+
+// ScheduleService schedules a downtime for a service, returning the created
+// downtime.
+func (d *Downtimes) ScheduleService(ctx context.Context, host, service string, spec DowntimeSpec) ([]Downtime, error) {
+	op := ScheduleSvcDowntime(host, service, spec.Start, spec.End, spec.Fixed, spec.TriggerID, spec.Duration, spec.Author, spec.Comment)
+	return d.schedule(ctx, op, spec, 1, "host_name = "+host, "service_description = "+service, "is_service = 1")
+}
+
+// ScheduleHostgroup schedules a downtime for every host of a hostgroup,
+// returning the created downtimes.
+func (d *Downtimes) ScheduleHostgroup(ctx context.Context, group string, spec DowntimeSpec) ([]Downtime, error) {
+	n, err := d.members(ctx, "hostgroups", group)
+	if err != nil {
+		return nil, err
+	}
+	op := ScheduleHostgroupHostDowntime(group, spec.Start, spec.End, spec.Fixed, spec.TriggerID, spec.Duration, spec.Author, spec.Comment)
+	return d.schedule(ctx, op, spec, n, "host_groups >= "+group, "is_service = 0")
+}
+
+// ScheduleServicegroup schedules a downtime for every service of a
+// servicegroup, returning the created downtimes.
+func (d *Downtimes) ScheduleServicegroup(ctx context.Context, group string, spec DowntimeSpec) ([]Downtime, error) {
+	n, err := d.members(ctx, "servicegroups", group)
+	if err != nil {
+		return nil, err
+	}
+	op := ScheduleServicegroupSvcDowntime(group, spec.Start, spec.End, spec.Fixed, spec.TriggerID, spec.Duration, spec.Author, spec.Comment)
+	return d.schedule(ctx, op, spec, n, "service_groups >= "+group, "is_service = 1")
+}
+
+// List returns the downtimes matching the filters, or all downtimes if none
+// are given.
+func (d *Downtimes) List(ctx context.Context, filters ...string) ([]Downtime, error) {
+	q := d.ls.Query("downtimes").Columns(downtimeColumns...)
+	for _, f := range filters {
+		q.Filter(f)
+	}
+
+	resp, err := q.ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Downtime, len(resp.Records))
+	for i, r := range resp.Records {
+		if res[i], err = decodeDowntime(r); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// FindByComment returns the downtimes with the given comment, its line
+// breaks replaced by spaces as when scheduled.
+func (d *Downtimes) FindByComment(ctx context.Context, comment string) ([]Downtime, error) {
+	return d.List(ctx, "comment = "+textEscaper.Replace(comment))
+}
+
+// Cancel deletes the given downtimes.
+func (d *Downtimes) Cancel(ctx context.Context, dts ...Downtime) error {
+	for _, dt := range dts {
+		op := DelHostDowntime(dt.ID)
+		if dt.Service != "" {
+			op = DelSvcDowntime(dt.ID)
+		}
+		if err := send(ctx, d.ls, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CancelByComment deletes all the downtimes with the given comment, returning
+// the deleted downtimes.
+func (d *Downtimes) CancelByComment(ctx context.Context, comment string) ([]Downtime, error) {
+	dts, err := d.FindByComment(ctx, comment)
+	if err != nil {
+		return nil, err
+	}
+	return dts, d.Cancel(ctx, dts...)
+}
+
+// schedule sends the command and polls the downtimes table until n new
+// downtimes matching the spec and filters show up.
+func (d *Downtimes) schedule(ctx context.Context, op lvst.CommandOpFunc, spec DowntimeSpec, n int, filters ...string) ([]Downtime, error) {
+	filters = append(filters,
+		"author = "+textEscaper.Replace(spec.Author),
+		"comment = "+textEscaper.Replace(spec.Comment),
+		fmt.Sprintf("start_time = %d", spec.Start.Unix()),
+		fmt.Sprintf("end_time = %d", spec.End.Unix()),
+	)
+
+	// Identical downtimes may already exist, so only report new ones
+	existing, err := d.List(ctx, filters...)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[int]bool{}
+	for _, dt := range existing {
+		seen[dt.ID] = true
+	}
+
+	c := d.ls.Command()
+	c.Op(op)
+	if _, err = c.ExecContext(ctx); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		// No downtime will show up for empty groups
+		return nil, nil
+	}
+
+	wctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	for {
+		dts, err := d.List(wctx, filters...)
+		if err != nil && wctx.Err() == nil {
+			return nil, err
+		}
+
+		var res []Downtime
+		for _, dt := range dts {
+			if !seen[dt.ID] {
+				res = append(res, dt)
+			}
+		}
+		if len(res) >= n {
+			return res, nil
+		}
+
+		select {
+		case <-wctx.Done():
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if len(res) > 0 {
+				return res, nil
+			}
+			return nil, ErrDowntimeNotFound
+		case <-time.After(d.PollInterval):
+		}
+	}
+}
+
+// members returns the number of members of a host or service group.
+func (d *Downtimes) members(ctx context.Context, table, group string) (int, error) {
+	resp, err := d.ls.Query(table).Columns("num_members").Filter("name = " + group).ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Records) == 0 {
+		return 0, fmt.Errorf("unknown %s %s", table[:len(table)-1], group)
+	}
+	n, err := resp.Records[0].GetInt("num_members")
+	return int(n), err
+}
+
+func decodeDowntime(r lvst.Record) (Downtime, error) {
+	var (
+		dt  Downtime
+		err error
+		id  int64
+	)
+
+	if id, err = r.GetInt("id"); err != nil {
+		return dt, err
+	}
+	dt.ID = int(id)
+	if dt.Host, err = r.GetString("host_name"); err != nil {
+		return dt, err
+	}
+	isService, err := r.GetBool("is_service")
+	if err != nil {
+		return dt, err
+	}
+	if isService {
+		if dt.Service, err = r.GetString("service_description"); err != nil {
+			return dt, err
+		}
+	}
+	if dt.Author, err = r.GetString("author"); err != nil {
+		return dt, err
+	}
+	if dt.Comment, err = r.GetString("comment"); err != nil {
+		return dt, err
+	}
+	if dt.Start, err = r.GetTime("start_time"); err != nil {
+		return dt, err
+	}
+	if dt.End, err = r.GetTime("end_time"); err != nil {
+		return dt, err
+	}
+	if dt.Entry, err = r.GetTime("entry_time"); err != nil {
+		return dt, err
+	}
+	if dt.Fixed, err = r.GetBool("fixed"); err != nil {
+		return dt, err
+	}
+	if dt.Duration, err = r.GetDuration("duration"); err != nil {
+		return dt, err
+	}
+	if id, err = r.GetInt("triggered_by"); err != nil {
+		return dt, err
+	}
+	dt.TriggeredBy = int(id)
+
+	return dt, nil
+}
@@ -0,0 +1,188 @@
+package nagios
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var downtimeRow = `[12,"db1","",0,"admin","upgrade",1439633040,1439640240,1439630000,1,7200,0]`
+
+func Test_DowntimesScheduleHost(t *testing.T) {
+	var srv *fakeServer
+	ls, srv := newFakeLivestatus(func(req string) (int, string) {
+		// The downtime only shows up once scheduled, and after an
+		// older identical one
+		rows := []string{`[3,"db1","",0,"admin","upgrade",1439633040,1439640240,1439620000,1,7200,0]`}
+		if len(srv.Commands()) > 0 {
+			rows = append(rows, downtimeRow)
+		}
+		return 200, "[" + strings.Join(rows, ",") + "]"
+	})
+
+	dts := NewDowntimes(ls)
+	dts.PollInterval = time.Millisecond
+
+	result, err := dts.ScheduleHost(context.Background(), "db1", DowntimeSpec{
+		Start:    time.Unix(1439633040, 0),
+		End:      time.Unix(1439640240, 0),
+		Fixed:    true,
+		Duration: 2 * time.Hour,
+		Author:   "admin",
+		Comment:  "upgrade",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Downtime{
+		Downtime{
+			ID:       12,
+			Host:     "db1",
+			Author:   "admin",
+			Comment:  "upgrade",
+			Start:    time.Unix(1439633040, 0),
+			End:      time.Unix(1439640240, 0),
+			Entry:    time.Unix(1439630000, 0),
+			Fixed:    true,
+			Duration: 2 * time.Hour,
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	cmds := srv.Commands()
+	expectedCmd := regexp.MustCompile(`^COMMAND \[[0-9]+\] SCHEDULE_HOST_DOWNTIME;db1;1439633040;1439640240;1;0;7200;admin;upgrade$`)
+	if len(cmds) != 1 || !expectedCmd.MatchString(cmds[0]) {
+		t.Logf("\nExpected %s\nbut got  %q\n", expectedCmd, cmds)
+		t.Fail()
+	}
+
+	expectedQuery := "GET downtimes\n"
+	expectedQuery += "Columns: " + strings.Join(downtimeColumns, " ") + "\n"
+	expectedQuery += "Filter: host_name = db1\n"
+	expectedQuery += "Filter: is_service = 0\n"
+	expectedQuery += "Filter: author = admin\n"
+	expectedQuery += "Filter: comment = upgrade\n"
+	expectedQuery += "Filter: start_time = 1439633040\n"
+	expectedQuery += "Filter: end_time = 1439640240\n"
+	if q := srv.Queries()[0]; !strings.HasPrefix(q, expectedQuery) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expectedQuery, q)
+		t.Fail()
+	}
+}
+
+func Test_DowntimesScheduleTimeout(t *testing.T) {
+	ls, _ := newFakeLivestatus(func(req string) (int, string) {
+		return 200, "[]"
+	})
+
+	dts := NewDowntimes(ls)
+	dts.PollInterval = time.Millisecond
+	dts.Timeout = 10 * time.Millisecond
+
+	_, err := dts.ScheduleService(context.Background(), "db1", "Disk", DowntimeSpec{
+		Start: time.Unix(1439633040, 0),
+		End:   time.Unix(1439640240, 0),
+	})
+	if err != ErrDowntimeNotFound {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrDowntimeNotFound, err)
+		t.Fail()
+	}
+}
+
+func Test_DowntimesScheduleEmptyGroup(t *testing.T) {
+	ls, _ := newFakeLivestatus(func(req string) (int, string) {
+		if strings.HasPrefix(req, "GET hostgroups\n") {
+			return 200, "[[0]]"
+		}
+		return 200, "[]"
+	})
+
+	// The empty group returns at once rather than after the timeout
+	dts := NewDowntimes(ls)
+	dts.Timeout = time.Hour
+
+	result, err := dts.ScheduleHostgroup(context.Background(), "empty", DowntimeSpec{
+		Start: time.Unix(1439633040, 0),
+		End:   time.Unix(1439640240, 0),
+	})
+	if err != nil || result != nil {
+		t.Logf("\nExpected no downtime\nbut got  %#v, %#v\n", result, err)
+		t.Fail()
+	}
+}
+
+func Test_DowntimesScheduleServiceEscaping(t *testing.T) {
+	var srv *fakeServer
+	ls, srv := newFakeLivestatus(func(req string) (int, string) {
+		if len(srv.Commands()) == 0 {
+			return 200, "[]"
+		}
+		return 200, `[[14,"db1","Disk",1,"admin","disk upgrade",1439633040,1439640240,1439630000,1,7200,0]]`
+	})
+
+	dts := NewDowntimes(ls)
+	dts.PollInterval = time.Millisecond
+
+	_, err := dts.ScheduleService(context.Background(), "db1", "Disk", DowntimeSpec{
+		Start:   time.Unix(1439633040, 0),
+		End:     time.Unix(1439640240, 0),
+		Fixed:   true,
+		Author:  "admin",
+		Comment: "disk\nupgrade",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmds := srv.Commands()
+	expectedCmd := regexp.MustCompile(`^COMMAND \[[0-9]+\] SCHEDULE_SVC_DOWNTIME;db1;Disk;1439633040;1439640240;1;0;0;admin;disk upgrade$`)
+	if len(cmds) != 1 || !expectedCmd.MatchString(cmds[0]) {
+		t.Logf("\nExpected %s\nbut got  %q\n", expectedCmd, cmds)
+		t.Fail()
+	}
+	if q := srv.Queries()[0]; !strings.Contains(q, "Filter: comment = disk upgrade\n") {
+		t.Logf("\nUnexpected query %q\n", q)
+		t.Fail()
+	}
+}
+
+func Test_DowntimesCancelByComment(t *testing.T) {
+	ls, srv := newFakeLivestatus(func(req string) (int, string) {
+		return 200, "[" + downtimeRow + `,[13,"db1","Disk",1,"admin","upgrade",1439633040,1439640240,1439630000,1,7200,12]]`
+	})
+
+	dts := NewDowntimes(ls)
+
+	result, err := dts.CancelByComment(context.Background(), "upgrade")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 || result[1].Service != "Disk" || result[1].TriggeredBy != 12 {
+		t.Logf("\nUnexpected downtimes %#v\n", result)
+		t.Fail()
+	}
+
+	if q := srv.Queries()[0]; !strings.Contains(q, "Filter: comment = upgrade\n") {
+		t.Logf("\nUnexpected query %q\n", q)
+		t.Fail()
+	}
+
+	// Wait for the commands sent on the kept alive connection
+	ls.Close()
+	for i := 0; len(srv.Commands()) < 2 && i < 100; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	cmds := srv.Commands()
+	if len(cmds) != 2 || !strings.HasSuffix(cmds[0], "DEL_HOST_DOWNTIME;12") || !strings.HasSuffix(cmds[1], "DEL_SVC_DOWNTIME;13") {
+		t.Logf("\nUnexpected commands %q\n", cmds)
+		t.Fail()
+	}
+}
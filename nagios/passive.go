@@ -0,0 +1,192 @@
+package nagios
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+// ErrResultNotProcessed is returned when the last check of an object does
+// not move after submitting a passive result, before the timeout.
+var ErrResultNotProcessed = errors.New("passive check result not processed")
+
+var outputEscaper = strings.NewReplacer(`\`, `\\`, "\r", "", "\n", `\n`)
+
+// PassiveResult is a passive check result for a host, or a service when
+// Service is set. Status is the plugin return code, 0-3 for services and 0-2
+// for hosts.
+type PassiveResult struct {
+	Host       string
+	Service    string
+	Status     int
+	Output     string
+	LongOutput string
+	PerfData   []lvst.PerfData
+}
+
+// Object returns the host or service the result is for.
+func (r PassiveResult) Object() Object {
+	return Object{Host: r.Host, Service: r.Service}
+}
+
+// Validate checks the result can be submitted without being misinterpreted
+// by Nagios.
+func (r PassiveResult) Validate() error {
+	if r.Host == "" {
+		return errors.New("passive result has no host")
+	}
+	if strings.ContainsAny(r.Host, ";\n") || strings.ContainsAny(r.Service, ";\n") {
+		return fmt.Errorf("invalid passive result object %q %q", r.Host, r.Service)
+	}
+
+	max := 3
+	if r.Service == "" {
+		max = 2
+	}
+	if r.Status < 0 || r.Status > max {
+		return fmt.Errorf("invalid passive result status %d", r.Status)
+	}
+
+	if strings.Contains(r.Output, "\n") {
+		return errors.New("passive result output must be a single line, use LongOutput for more")
+	}
+	if strings.Contains(r.Output, "|") || strings.Contains(r.LongOutput, "|") {
+		return errors.New("passive result output can't contain |, which starts performance data")
+	}
+
+	for _, pd := range r.PerfData {
+		if pd.Label == "" || strings.ContainsAny(pd.Label, "\n") {
+			return fmt.Errorf("invalid performance data label %q", pd.Label)
+		}
+		if strings.ContainsAny(pd.Unit, "0123456789.;' \t\n") {
+			return fmt.Errorf("invalid performance data unit %q", pd.Unit)
+		}
+	}
+
+	return nil
+}
+
+// Format returns the plugin output of the result, with the performance data
+// following the first line of output and newlines escaped as Nagios expects
+// in external commands.
+func (r PassiveResult) Format() string {
+	out := r.Output
+	if len(r.PerfData) > 0 {
+		out += "|" + lvst.FormatPerfData(r.PerfData)
+	}
+	if r.LongOutput != "" {
+		out += "\n" + r.LongOutput
+	}
+	return outputEscaper.Replace(out)
+}
+
+// Op returns the command submitting the result.
+func (r PassiveResult) Op() lvst.CommandOpFunc {
+	if r.Service == "" {
+		return ProcessHostCheckResult(r.Host, r.Status, r.Format())
+	}
+	return ProcessServiceCheckResult(r.Host, r.Service, r.Status, r.Format())
+}
+
+// Passive submits passive check results to a Livestatus instance.
+type Passive struct {
+	ls *lvst.Livestatus
+
+	// Verify waits for the last check of every object to move after
+	// submitting results.
+	Verify bool
+	// PollInterval is the delay between queries checking results have been
+	// processed.
+	PollInterval time.Duration
+	// Timeout is how long to wait for results to be processed.
+	Timeout time.Duration
+}
+
+// NewPassive creates a new passive result submitter.
+func NewPassive(ls *lvst.Livestatus) *Passive {
+	return &Passive{
+		ls:           ls,
+		PollInterval: 500 * time.Millisecond,
+		Timeout:      30 * time.Second,
+	}
+}
+
+// Submit validates and submits the results. No result is submitted if any
+// of them is invalid.
+func (p *Passive) Submit(ctx context.Context, rs ...PassiveResult) error {
+	for _, r := range rs {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+
+	var before map[Object]time.Time
+	if p.Verify {
+		before = map[Object]time.Time{}
+		for _, r := range rs {
+			last, err := p.lastCheck(ctx, r.Object())
+			if err != nil {
+				return err
+			}
+			before[r.Object()] = last
+		}
+	}
+
+	for _, r := range rs {
+		if err := send(ctx, p.ls, r.Op()); err != nil {
+			return err
+		}
+	}
+
+	if !p.Verify {
+		return nil
+	}
+
+	wctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	for obj, last := range before {
+		for {
+			cur, err := p.lastCheck(wctx, obj)
+			if err != nil && wctx.Err() == nil {
+				return err
+			}
+			if err == nil && cur.After(last) {
+				break
+			}
+
+			select {
+			case <-wctx.Done():
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				return ErrResultNotProcessed
+			case <-time.After(p.PollInterval):
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *Passive) lastCheck(ctx context.Context, obj Object) (time.Time, error) {
+	q := p.ls.Query("hosts").Columns("last_check").Filter("name = " + obj.Host)
+	if obj.Service != "" {
+		q = p.ls.Query("services").Columns("last_check").
+			Filter("host_name = " + obj.Host).
+			Filter("description = " + obj.Service)
+	}
+
+	resp, err := q.ExecContext(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(resp.Records) == 0 {
+		return time.Time{}, fmt.Errorf("unknown object %s %s", obj.Host, obj.Service)
+	}
+	return resp.Records[0].GetTime("last_check")
+}
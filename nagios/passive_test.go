@@ -0,0 +1,137 @@
+package nagios
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+func Test_PassiveResultFormat(t *testing.T) {
+	max := 100.0
+	r := PassiveResult{
+		Host:       "db1",
+		Service:    "Disk",
+		Status:     1,
+		Output:     `WARNING - C:\ 85% used`,
+		LongOutput: "/var 85%\n/home 10%",
+		PerfData: []lvst.PerfData{
+			{Label: "/var", Value: 85, Unit: "%", Warn: &lvst.Range{End: 80}, Max: &max},
+			{Label: "home dir", Value: 10, Unit: "%"},
+		},
+	}
+	if err := r.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `WARNING - C:\\ 85% used|/var=85%;80;;;100 'home dir'=10%\n/var 85%\n/home 10%`
+	if result := r.Format(); result != expected {
+		t.Logf("\nExpected %q\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_PassiveResultValidate(t *testing.T) {
+	for _, r := range []PassiveResult{
+		{Output: "no host"},
+		{Host: "db1;db2"},
+		{Host: "db1", Status: 3},
+		{Host: "db1", Service: "Disk", Status: 4},
+		{Host: "db1", Output: "two\nlines"},
+		{Host: "db1", Output: "a|b"},
+		{Host: "db1", PerfData: []lvst.PerfData{{Value: 1}}},
+		{Host: "db1", PerfData: []lvst.PerfData{{Label: "a", Unit: "1s"}}},
+	} {
+		if err := r.Validate(); err == nil {
+			t.Logf("\nExpected error for %#v\n", r)
+			t.Fail()
+		}
+	}
+}
+
+func Test_PassiveSubmit(t *testing.T) {
+	ls, srv := newFakeLivestatus(func(req string) (int, string) {
+		return 200, "[]"
+	})
+
+	err := NewPassive(ls).Submit(context.Background(),
+		PassiveResult{Host: "db1", Status: 0, Output: "UP"},
+		PassiveResult{Host: "db1", Service: "Load", Status: 2, Output: "CRITICAL"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedCmds := []*regexp.Regexp{
+		regexp.MustCompile(`^COMMAND \[[0-9]+\] PROCESS_HOST_CHECK_RESULT;db1;0;UP$`),
+		regexp.MustCompile(`^COMMAND \[[0-9]+\] PROCESS_SERVICE_CHECK_RESULT;db1;Load;2;CRITICAL$`),
+	}
+	cmds := srv.WaitCommands(len(expectedCmds))
+	if len(cmds) != len(expectedCmds) {
+		t.Fatalf("\nExpected %d commands\nbut got  %q\n", len(expectedCmds), cmds)
+	}
+	for i, re := range expectedCmds {
+		if !re.MatchString(cmds[i]) {
+			t.Logf("\nExpected %s\nbut got  %q\n", re, cmds[i])
+			t.Fail()
+		}
+	}
+
+	// Nothing is sent if any result is invalid
+	err = NewPassive(ls).Submit(context.Background(),
+		PassiveResult{Host: "db1", Status: 0, Output: "UP"},
+		PassiveResult{Host: "db1", Status: 5},
+	)
+	if err == nil || len(srv.Commands()) != 2 {
+		t.Logf("\nExpected error and no command sent, got %v %q\n", err, srv.Commands())
+		t.Fail()
+	}
+}
+
+func Test_PassiveSubmitVerify(t *testing.T) {
+	var srv *fakeServer
+	ls, srv := newFakeLivestatus(func(req string) (int, string) {
+		if len(srv.Commands()) > 0 {
+			return 200, "[[1439640000]]"
+		}
+		return 200, "[[1439630000]]"
+	})
+
+	p := NewPassive(ls)
+	p.Verify = true
+	p.PollInterval = time.Millisecond
+
+	err := p.Submit(context.Background(), PassiveResult{Host: "db1", Service: "Load", Output: "OK"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedQuery := "GET services\n"
+	expectedQuery += "Columns: last_check\n"
+	expectedQuery += "Filter: host_name = db1\n"
+	expectedQuery += "Filter: description = Load\n"
+	if q := srv.Queries()[0]; !strings.HasPrefix(q, expectedQuery) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expectedQuery, q)
+		t.Fail()
+	}
+}
+
+func Test_PassiveSubmitVerifyTimeout(t *testing.T) {
+	ls, _ := newFakeLivestatus(func(req string) (int, string) {
+		return 200, "[[1439630000]]"
+	})
+
+	p := NewPassive(ls)
+	p.Verify = true
+	p.PollInterval = time.Millisecond
+	p.Timeout = 20 * time.Millisecond
+
+	err := p.Submit(context.Background(), PassiveResult{Host: "db1", Output: "UP"})
+	if err != ErrResultNotProcessed {
+		t.Logf("\nExpected %v\nbut got  %v\n", ErrResultNotProcessed, err)
+		t.Fail()
+	}
+}
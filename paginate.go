@@ -0,0 +1,157 @@
+package livestatus
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Paginator pages through the records of a query, using filters on key
+// columns rather than offsets, which Livestatus lacks. Each page holds the
+// records following the last one of the previous page in key order.
+//
+// Livestatus must return records ordered by the key columns, as it does for
+// hosts by name, and for services by host_name and description, pages
+// failing with ErrUnorderedRecords otherwise.
+type Paginator struct {
+	query *Query
+	keys  []string
+	size  int
+}
+
+// NewPaginator creates a paginator returning pages of up to size records of
+// a query, ordered by the given key columns, a size of 0 returning a single
+// page. The key columns are added to those of the query when missing, and
+// any Limit header is replaced. It fails with ErrNoPageKeys when no key
+// columns are given.
+func NewPaginator(q *Query, size int, keys ...string) (*Paginator, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoPageKeys
+	}
+
+	q = q.Clone()
+	if len(q.columns) > 0 {
+		cols := q.columns
+		for _, k := range keys {
+			if !containsString(cols, k) {
+				cols = append(cols, k)
+			}
+		}
+		q.ReplaceHeader("Columns", strings.Join(cols, " "))
+	}
+	if size > 0 {
+		q.ReplaceHeader("Limit", fmt.Sprintf("%d", size))
+	}
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	return &Paginator{query: q, keys: keys, size: size}, nil
+}
+
+// Page returns the page following the one a continuation token was returned
+// with, or the first page for an empty token. The token of the next page is
+// empty once the last page is reached.
+func (p *Paginator) Page(ctx context.Context, token string) (*Response, string, error) {
+	q := p.query.Clone()
+
+	if token != "" {
+		last, err := p.decodeToken(token)
+		if err != nil {
+			return nil, "", err
+		}
+		q.Where(p.after(last))
+	}
+
+	resp, err := q.ExecContext(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Records out of order would be missed by the following pages, as
+	// Livestatus applies the limit before any sorting could be done here
+	for i := 1; i < resp.Len(); i++ {
+		if p.compare(resp.record(i-1), resp.record(i)) > 0 {
+			return nil, "", ErrUnorderedRecords
+		}
+	}
+
+	if p.size <= 0 || resp.Len() < p.size {
+		return resp, "", nil
+	}
+
+	next, err := p.encodeToken(resp.record(resp.Len() - 1))
+	if err != nil {
+		return nil, "", err
+	}
+	return resp, next, nil
+}
+
+// after returns the filter matching the records following the given key
+// values, such as `k1 > v1 or (k1 = v1 and k2 > v2)` for two keys.
+func (p *Paginator) after(last []interface{}) Filter {
+	var alts FilterOr
+	for i, k := range p.keys {
+		var f FilterAnd
+		for j := 0; j < i; j++ {
+			f = append(f, FilterRule{Column: p.keys[j], Op: "=", Value: formatValue(last[j])})
+		}
+		f = append(f, FilterRule{Column: k, Op: ">", Value: formatValue(last[i])})
+
+		if len(f) == 1 {
+			alts = append(alts, f[0])
+		} else {
+			alts = append(alts, f)
+		}
+	}
+
+	if len(alts) == 1 {
+		return alts[0]
+	}
+	return alts
+}
+
+// compare orders records by their key values.
+func (p *Paginator) compare(a, b Record) int {
+	for _, k := range p.keys {
+		if c := compareValues(a[k], b[k]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func (p *Paginator) encodeToken(r Record) (string, error) {
+	last := make([]interface{}, len(p.keys))
+	for i, k := range p.keys {
+		last[i] = r[k]
+	}
+	b, err := json.Marshal(last)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (p *Paginator) decodeToken(token string) ([]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var last []interface{}
+	if err := json.Unmarshal(b, &last); err != nil || len(last) != len(p.keys) {
+		return nil, ErrInvalidToken
+	}
+	return last, nil
+}
+
+func containsString(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
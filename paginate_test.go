@@ -0,0 +1,178 @@
+package livestatus
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// servicesHandler answers services queries from a list of services sorted by
+// host name and description, applying their filters and limit.
+func servicesHandler(services [][2]string) func(string) (int, string) {
+	return func(req string) (int, string) {
+		var stack FilterStack
+		limit := 0
+		for _, line := range strings.Split(req, "\n")[1:] {
+			i := strings.Index(line, ":")
+			if i == -1 {
+				continue
+			}
+			name, value := line[:i], strings.TrimSpace(line[i+1:])
+			n, _ := strconv.Atoi(value)
+			switch name {
+			case "Filter":
+				f, err := ParseFilterRule(value)
+				if err != nil {
+					return 400, err.Error()
+				}
+				stack.Push(f)
+			case "And":
+				stack.And(n)
+			case "Or":
+				stack.Or(n)
+			case "Limit":
+				limit = n
+			}
+		}
+
+		filter := stack.Filter()
+		var rows []string
+		for _, s := range services {
+			if limit > 0 && len(rows) >= limit {
+				break
+			}
+			r := Record{"host_name": s[0], "description": s[1]}
+			if filter == nil || filter.Match(r) {
+				rows = append(rows, fmt.Sprintf("[%q,%q]", s[0], s[1]))
+			}
+		}
+		return 200, "[" + strings.Join(rows, ",") + "]"
+	}
+}
+
+func Test_Paginator(t *testing.T) {
+	services := [][2]string{
+		{"db1", "Disk"}, {"db1", "Load"}, {"db1", "Ping"},
+		{"db2", "Disk"}, {"db2", "Load"},
+		{"web1", "HTTP"}, {"web1", "Load"},
+	}
+	ls, s := newFakeLivestatus(servicesHandler(services))
+
+	q := ls.Query("services").Columns("host_name").Limit(1000)
+	p, err := NewPaginator(q, 3, "host_name", "description")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result [][2]string
+	token := ""
+	pages := 0
+	for {
+		resp, next, err := p.Page(context.Background(), token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, r := range resp.Records {
+			result = append(result, [2]string{r["host_name"].(string), r["description"].(string)})
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+
+	if !reflect.DeepEqual(result, services) || pages != 3 {
+		t.Logf("\nExpected %#v in 3 pages\nbut got  %#v in %d pages\n", services, result, pages)
+		t.Fail()
+	}
+
+	expected := "GET services\n" +
+		"Columns: host_name description\n" +
+		"Limit: 3\n" +
+		"Filter: host_name > db1\n" +
+		"Filter: host_name = db1\n" +
+		"Filter: description > Ping\n" +
+		"And: 2\n" +
+		"Or: 2\n" +
+		"ResponseHeader: fixed16\nOutputFormat: json\n\n"
+	if queries := s.Queries(); len(queries) < 2 || queries[1] != expected {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, queries)
+		t.Fail()
+	}
+
+	// The base query is left unchanged
+	if result := q.String(); result != "GET services\nColumns: host_name\nLimit: 1000\n" {
+		t.Logf("\nExpected the base query to be unchanged\nbut got  %#v\n", result)
+		t.Fail()
+	}
+
+	if _, _, err := p.Page(context.Background(), "bogus!"); err != ErrInvalidToken {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrInvalidToken, err)
+		t.Fail()
+	}
+
+	unordered, _ := newFakeLivestatus(servicesHandler([][2]string{
+		{"db2", "Disk"}, {"db1", "Disk"},
+	}))
+	p, err = NewPaginator(unordered.Query("services").Columns("host_name", "description"), 3, "host_name", "description")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.Page(context.Background(), ""); err != ErrUnorderedRecords {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrUnorderedRecords, err)
+		t.Fail()
+	}
+}
+
+func Test_PaginatorRowsOnly(t *testing.T) {
+	services := [][2]string{
+		{"db1", "Disk"}, {"db1", "Load"}, {"db2", "Disk"}, {"web1", "HTTP"},
+	}
+	ls, _ := newFakeLivestatus(servicesHandler(services))
+
+	p, err := NewPaginator(ls.Query("services").Columns("host_name", "description").RowsOnly(), 2, "host_name", "description")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result [][2]string
+	token := ""
+	for {
+		resp, next, err := p.Page(context.Background(), token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range resp.Rows {
+			result = append(result, [2]string{r[0].(string), r[1].(string)})
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+
+	if !reflect.DeepEqual(result, services) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", services, result)
+		t.Fail()
+	}
+}
+
+func Test_PaginatorErrors(t *testing.T) {
+	ls, _ := newFakeLivestatus(servicesHandler(nil))
+
+	if _, err := NewPaginator(ls.Query("services"), 2); err != ErrNoPageKeys {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrNoPageKeys, err)
+		t.Fail()
+	}
+
+	// Header edit errors of the query are returned rather than sent
+	q := ls.Query("services").Filter("state = 1").Filter("state = 2").Or(2).RemoveHeader("Filter")
+	if _, err := NewPaginator(q, 2, "host_name"); err == nil {
+		t.Logf("\nExpected an error for %q\n", q)
+		t.Fail()
+	}
+}
@@ -0,0 +1,290 @@
+package livestatus
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PerfData is a single performance data metric as reported by a Nagios
+// plugin, in the form 'label'=value[UOM];[warn];[crit];[min];[max].
+type PerfData struct {
+	Label string
+	Value float64
+	Unit  string
+	Warn  *Range
+	Crit  *Range
+	Min   *float64
+	Max   *float64
+}
+
+// Range is a Nagios plugin threshold range, in the form [@]start:end.
+// Unbounded ends are represented by infinite values.
+type Range struct {
+	Start  float64
+	End    float64
+	Inside bool
+}
+
+// Alert reports whether the value v should raise an alert for the range. By
+// default values outside of the range alert, unless the range was prefixed
+// with `@` in which case values inside the range alert.
+func (r Range) Alert(v float64) bool {
+	in := v >= r.Start && v <= r.End
+	if r.Inside {
+		return in
+	}
+	return !in
+}
+
+// String formats the range in the Nagios plugin threshold syntax.
+func (r Range) String() string {
+	var s string
+	if r.Inside {
+		s = "@"
+	}
+
+	switch {
+	case math.IsInf(r.Start, -1):
+		s += "~:"
+	case r.Start != 0 || math.IsInf(r.End, 1):
+		s += formatPerfFloat(r.Start) + ":"
+	}
+	if !math.IsInf(r.End, 1) {
+		s += formatPerfFloat(r.End)
+	}
+
+	return s
+}
+
+// String formats the metric in the Nagios plugin performance data syntax,
+// quoting the label when required. NaN values are formatted as `U`.
+func (p PerfData) String() string {
+	label := p.Label
+	if strings.ContainsAny(label, " \t='") {
+		label = "'" + strings.Replace(label, "'", "''", -1) + "'"
+	}
+
+	value := "U"
+	if !math.IsNaN(p.Value) {
+		value = formatPerfFloat(p.Value) + p.Unit
+	}
+
+	fields := []string{label + "=" + value, "", "", "", ""}
+	if p.Warn != nil {
+		fields[1] = p.Warn.String()
+	}
+	if p.Crit != nil {
+		fields[2] = p.Crit.String()
+	}
+	if p.Min != nil {
+		fields[3] = formatPerfFloat(*p.Min)
+	}
+	if p.Max != nil {
+		fields[4] = formatPerfFloat(*p.Max)
+	}
+
+	// Trailing empty fields can be omitted
+	for len(fields) > 1 && fields[len(fields)-1] == "" {
+		fields = fields[:len(fields)-1]
+	}
+
+	return strings.Join(fields, ";")
+}
+
+// FormatPerfData formats a slice of metrics as a Nagios plugin performance
+// data string.
+func FormatPerfData(pds []PerfData) string {
+	strs := make([]string, len(pds))
+	for i, pd := range pds {
+		strs[i] = pd.String()
+	}
+	return strings.Join(strs, " ")
+}
+
+// ParsePerfData parses a Nagios plugin performance data string into a slice
+// of metrics. A value of `U` is reported as NaN.
+func ParsePerfData(s string) ([]PerfData, error) {
+	var res []PerfData
+
+	s = strings.TrimSpace(s)
+	for len(s) > 0 {
+		var (
+			label string
+			err   error
+		)
+
+		label, s, err = parsePerfLabel(s)
+		if err != nil {
+			return nil, err
+		}
+
+		// The remainder of the metric runs up to the next whitespace
+		end := strings.IndexAny(s, " \t\n")
+		if end == -1 {
+			end = len(s)
+		}
+		pd, err := parsePerfFields(label, s[:end])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, pd)
+
+		s = strings.TrimLeft(s[end:], " \t\n")
+	}
+
+	return res, nil
+}
+
+// GetPerfData returns the parsed performance data for a specific column,
+// such as perf_data.
+//
+// Returns an error if the column is unknown, if the value is not a string, or
+// if it can't be parsed as performance data.
+func (r Record) GetPerfData(name string) ([]PerfData, error) {
+	v, err := r.GetString(name)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePerfData(v)
+}
+
+// parsePerfLabel reads a possibly quoted label up to its `=` sign, returning
+// the label and the remainder of the string following the `=`.
+func parsePerfLabel(s string) (string, string, error) {
+	if s[0] != '\'' {
+		i := strings.IndexByte(s, '=')
+		if i <= 0 || strings.ContainsAny(s[:i], " \t\n") {
+			return "", "", fmt.Errorf("invalid perfdata label in %q", s)
+		}
+		return s[:i], s[i+1:], nil
+	}
+
+	// Quoted labels escape single quotes by doubling them
+	var label []byte
+	for i := 1; i < len(s); i++ {
+		if s[i] != '\'' {
+			label = append(label, s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '\'' {
+			label = append(label, '\'')
+			i++
+			continue
+		}
+		if i+1 >= len(s) || s[i+1] != '=' {
+			return "", "", fmt.Errorf("invalid perfdata label in %q", s)
+		}
+		return string(label), s[i+2:], nil
+	}
+
+	return "", "", fmt.Errorf("unterminated perfdata label in %q", s)
+}
+
+func parsePerfFields(label, s string) (PerfData, error) {
+	pd := PerfData{Label: label}
+
+	fields := strings.Split(s, ";")
+	if len(fields) > 5 {
+		return pd, fmt.Errorf("too many perfdata fields for %q", label)
+	}
+
+	// Split the unit of measure from the value
+	val := fields[0]
+	i := strings.LastIndexAny(val, "0123456789.") + 1
+	if val == "U" {
+		pd.Value = math.NaN()
+	} else {
+		if i == 0 {
+			return pd, fmt.Errorf("invalid perfdata value %q for %q", val, label)
+		}
+		f, err := strconv.ParseFloat(val[:i], 64)
+		if err != nil {
+			return pd, fmt.Errorf("invalid perfdata value %q for %q", val, label)
+		}
+		pd.Value = f
+		pd.Unit = val[i:]
+	}
+
+	var err error
+	if len(fields) > 1 && fields[1] != "" {
+		if pd.Warn, err = ParseRange(fields[1]); err != nil {
+			return pd, err
+		}
+	}
+	if len(fields) > 2 && fields[2] != "" {
+		if pd.Crit, err = ParseRange(fields[2]); err != nil {
+			return pd, err
+		}
+	}
+	if len(fields) > 3 && fields[3] != "" {
+		if pd.Min, err = parsePerfFloat(fields[3]); err != nil {
+			return pd, err
+		}
+	}
+	if len(fields) > 4 && fields[4] != "" {
+		if pd.Max, err = parsePerfFloat(fields[4]); err != nil {
+			return pd, err
+		}
+	}
+
+	return pd, nil
+}
+
+// ParseRange parses a Nagios plugin threshold range such as `10`, `10:`,
+// `~:10`, `10:20` or `@10:20`.
+func ParseRange(s string) (*Range, error) {
+	r := &Range{End: math.Inf(1)}
+
+	str := s
+	if strings.HasPrefix(str, "@") {
+		r.Inside = true
+		str = str[1:]
+	}
+
+	var start, end string
+	if i := strings.IndexByte(str, ':'); i == -1 {
+		end = str
+	} else {
+		start, end = str[:i], str[i+1:]
+	}
+
+	switch start {
+	case "":
+	case "~":
+		r.Start = math.Inf(-1)
+	default:
+		f, err := strconv.ParseFloat(start, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid perfdata range %q", s)
+		}
+		r.Start = f
+	}
+
+	if end != "" {
+		f, err := strconv.ParseFloat(end, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid perfdata range %q", s)
+		}
+		r.End = f
+	}
+
+	if r.Start > r.End {
+		return nil, fmt.Errorf("invalid perfdata range %q", s)
+	}
+
+	return r, nil
+}
+
+func parsePerfFloat(s string) (*float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid perfdata value %q", s)
+	}
+	return &f, nil
+}
+
+func formatPerfFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,171 @@
+package livestatus
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func Test_ParsePerfData(t *testing.T) {
+	data := "time=0.012s;1.000;5.000;0.000 'disk /var''s usage'=85%;80;90;0;100 users=3"
+
+	expected := []PerfData{
+		PerfData{
+			Label: "time",
+			Value: 0.012,
+			Unit:  "s",
+			Warn:  &Range{Start: 0, End: 1},
+			Crit:  &Range{Start: 0, End: 5},
+			Min:   floatPtr(0),
+		},
+		PerfData{
+			Label: "disk /var's usage",
+			Value: 85,
+			Unit:  "%",
+			Warn:  &Range{Start: 0, End: 80},
+			Crit:  &Range{Start: 0, End: 90},
+			Min:   floatPtr(0),
+			Max:   floatPtr(100),
+		},
+		PerfData{
+			Label: "users",
+			Value: 3,
+		},
+	}
+
+	result, err := ParsePerfData(data)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_ParsePerfDataUnknown(t *testing.T) {
+	result, err := ParsePerfData("load=U;;;0")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(result) != 1 || !math.IsNaN(result[0].Value) {
+		t.Logf("\nExpected NaN value\nbut got  %#v\n", result)
+		t.Fail()
+	}
+}
+
+func Test_ParsePerfDataInvalid(t *testing.T) {
+	for _, data := range []string{
+		"novalue",
+		"'unterminated=1",
+		"label=abc",
+		"label=1;2;3;4;5;6",
+		"label=1;20:10",
+	} {
+		if _, err := ParsePerfData(data); err == nil {
+			t.Logf("\nExpected error for %q\n", data)
+			t.Fail()
+		}
+	}
+}
+
+func Test_ParseRange(t *testing.T) {
+	tests := map[string]Range{
+		"10":     Range{Start: 0, End: 10},
+		"10:":    Range{Start: 10, End: math.Inf(1)},
+		"~:10":   Range{Start: math.Inf(-1), End: 10},
+		"10:20":  Range{Start: 10, End: 20},
+		"@10:20": Range{Start: 10, End: 20, Inside: true},
+	}
+
+	for data, expected := range tests {
+		result, err := ParseRange(data)
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(*result, expected) {
+			t.Logf("\nExpected %#v\nbut got  %#v\n", expected, *result)
+			t.Fail()
+		}
+	}
+}
+
+func Test_RangeString(t *testing.T) {
+	for _, data := range []string{"10", "10:", "~:10", "10:20", "@10:20", "0:", "-5:5"} {
+		r, err := ParseRange(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := r.String(); result != data {
+			t.Logf("\nExpected %q\nbut got  %q\n", data, result)
+			t.Fail()
+		}
+	}
+}
+
+func Test_FormatPerfData(t *testing.T) {
+	data := "time=0.012s;1;5;0 'disk /var''s usage'=85%;80;90;0;100 users=3 load=U;;;0"
+
+	pds, err := ParsePerfData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := FormatPerfData(pds); result != data {
+		t.Logf("\nExpected %q\nbut got  %q\n", data, result)
+		t.Fail()
+	}
+}
+
+func Test_RangeAlert(t *testing.T) {
+	r := Range{Start: 10, End: 20}
+	if r.Alert(15) || !r.Alert(5) || !r.Alert(25) {
+		t.Logf("\nUnexpected alert result for %#v\n", r)
+		t.Fail()
+	}
+
+	r.Inside = true
+	if !r.Alert(15) || r.Alert(5) || r.Alert(25) {
+		t.Logf("\nUnexpected alert result for %#v\n", r)
+		t.Fail()
+	}
+}
+
+func Test_RecordGetPerfData(t *testing.T) {
+	record := Record{
+		"perf_data": "rta=0.5ms;100;500;0",
+		"state":     0.0,
+	}
+
+	expected := []PerfData{
+		PerfData{
+			Label: "rta",
+			Value: 0.5,
+			Unit:  "ms",
+			Warn:  &Range{Start: 0, End: 100},
+			Crit:  &Range{Start: 0, End: 500},
+			Min:   floatPtr(0),
+		},
+	}
+
+	result, err := record.GetPerfData("perf_data")
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	if _, err = record.GetPerfData("state"); err != ErrInvalidValue {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrInvalidValue, err)
+		t.Fail()
+	}
+
+	result, err = Record{"perf_data": ""}.GetPerfData("perf_data")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(result) != 0 {
+		t.Logf("\nExpected no metrics\nbut got  %#v\n", result)
+		t.Fail()
+	}
+}
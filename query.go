@@ -2,6 +2,7 @@ package livestatus
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -64,6 +65,13 @@ type Query struct {
 	columns []string
 	ls      *Livestatus
 	waiting bool
+	stats   bool
+
+	rowsOnly bool
+
+	// err is the error of a refused header edit, returned when executing
+	// the query.
+	err error
 }
 
 // Columns sets the names of the columns to retrieve when executing a query.
@@ -76,6 +84,16 @@ func (q *Query) Columns(names ...string) *Query {
 // KeepAlive keeps the connection open after the query, for re-use
 func (q *Query) KeepAlive() *Query {
 	q.headers = append(q.headers, "KeepAlive: on")
+	if q.ls != nil {
+		q.ls.setKeepAlive(true)
+	}
+	return q
+}
+
+// AuthUser restricts the query to the objects the given contact is
+// authorized for.
+func (q *Query) AuthUser(name string) *Query {
+	q.headers = append(q.headers, "AuthUser: "+name)
 	return q
 }
 
@@ -109,11 +127,40 @@ func (q *Query) Limit(n int) *Query {
 	return q
 }
 
+// Stats adds a new statistics rule to the query. Stats columns are returned
+// after any requested columns, named stats_1, stats_2 and so on.
+func (q *Query) Stats(rule string) *Query {
+	q.headers = append(q.headers, "Stats: "+rule)
+	q.stats = true
+	return q
+}
+
+// StatsAnd combines the n last stats rules into a new rule using a `And`
+// operation.
+func (q *Query) StatsAnd(n int) *Query {
+	q.headers = append(q.headers, fmt.Sprintf("StatsAnd: %d", n))
+	return q
+}
+
+// StatsOr combines the n last stats rules into a new rule using a `Or`
+// operation.
+func (q *Query) StatsOr(n int) *Query {
+	q.headers = append(q.headers, fmt.Sprintf("StatsOr: %d", n))
+	return q
+}
+
+// StatsNegate negates the most recent stats rule.
+func (q *Query) StatsNegate() *Query {
+	q.headers = append(q.headers, "StatsNegate:")
+	return q
+}
+
 // WaitObject sets the object within the queried table to wait on. For the table
 // hosts, hostgroups, servicegroups, contacts and contactgroups this is simply
 // the name of the object. For the table services it is the hostname followed
 // by a space followed by the service description
 func (q *Query) WaitObject(obj string) *Query {
+	q.waiting = true
 	q.headers = append(q.headers, "WaitObject: "+obj)
 	return q
 }
@@ -128,6 +175,7 @@ func (q *Query) WaitCondition(rule string) *Query {
 // WaitConditionAnd combines the n last wait conditions into a new wait
 // condition using a `And` operation.
 func (q *Query) WaitConditionAnd(n int) *Query {
+	q.waiting = true
 	q.headers = append(q.headers, fmt.Sprintf("WaitConditionAnd: %d", n))
 	return q
 }
@@ -135,12 +183,14 @@ func (q *Query) WaitConditionAnd(n int) *Query {
 // WaitConditionOr combines the n last wait condition into a new wait condition
 // using a `Or` operation.
 func (q *Query) WaitConditionOr(n int) *Query {
+	q.waiting = true
 	q.headers = append(q.headers, fmt.Sprintf("WaitConditionOr: %d", n))
 	return q
 }
 
 // WaitConditionNegate negates the most recent wait condition.
 func (q *Query) WaitConditionNegate() *Query {
+	q.waiting = true
 	q.headers = append(q.headers, "WaitConditionNegate:")
 	return q
 }
@@ -148,21 +198,232 @@ func (q *Query) WaitConditionNegate() *Query {
 // WaitTrigger sets the nagios event that will trigger a check of
 // the wait condition.
 func (q *Query) WaitTrigger(event string) *Query {
+	q.waiting = true
 	q.headers = append(q.headers, "WaitTrigger: "+event)
 	return q
 }
 
 // WaitTimeout set a timeout for the wait condition.
 func (q *Query) WaitTimeout(t time.Duration) *Query {
+	q.waiting = true
 	q.headers = append(q.headers, fmt.Sprintf("WaitTimeout: %d", t/time.Millisecond))
 	return q
 }
 
+// RowsOnly leaves the records of the response unset, for callers only using
+// its rows, saving the allocation of a map per record.
+func (q *Query) RowsOnly() *Query {
+	q.rowsOnly = true
+	return q
+}
+
+// Clone returns a copy of the query, which can be extended without changing
+// the original. Queries only read when cloned can be shared between
+// goroutines.
+func (q *Query) Clone() *Query {
+	c := *q
+	c.headers = append(make([]string, 0, len(q.headers)), q.headers...)
+	c.columns = append([]string(nil), q.columns...)
+	return &c
+}
+
+// With returns a copy of the query extended by the given functions, leaving
+// the query unchanged.
+func (q *Query) With(fs ...func(q *Query)) *Query {
+	c := q.Clone()
+	for _, f := range fs {
+		f(c)
+	}
+	return c
+}
+
+// RemoveHeader removes all the headers with the given name, such as Limit or
+// Filter. Removing filters or stats referred to by And, Or or their variants
+// is refused, leaving the headers unchanged and failing the query when
+// executed.
+func (q *Query) RemoveHeader(name string) *Query {
+	var headers []string
+	for _, h := range q.headers {
+		if headerName(h) != name {
+			headers = append(headers, h)
+		}
+	}
+	return q.setHeaders(name, headers)
+}
+
+// ReplaceHeader replaces all the headers with the given name by a single one
+// with the given value, in place of the first of them, or adds it. Replacing
+// filters or stats referred to by And, Or or their variants is refused as by
+// RemoveHeader.
+func (q *Query) ReplaceHeader(name, value string) *Query {
+	h := name + ": " + value
+	if value == "" {
+		h = name + ":"
+	}
+
+	var headers []string
+	replaced := false
+	for _, e := range q.headers {
+		switch {
+		case headerName(e) != name:
+			headers = append(headers, e)
+		case !replaced:
+			headers = append(headers, h)
+			replaced = true
+		}
+	}
+	if !replaced {
+		headers = append(headers, h)
+	}
+	return q.setHeaders(name, headers)
+}
+
+// setHeaders sets the edited headers of the query, updating the state
+// derived from them.
+func (q *Query) setHeaders(name string, headers []string) *Query {
+	if err := checkCombinators(headers); err != nil {
+		if q.err == nil {
+			q.err = err
+		}
+		return q
+	}
+	q.headers = headers
+
+	q.columns, q.stats, q.waiting = nil, false, false
+	keepalive := false
+	for _, h := range q.headers {
+		switch headerName(h) {
+		case "Columns":
+			q.columns = strings.Fields(strings.TrimPrefix(h, "Columns:"))
+		case "Stats":
+			q.stats = true
+		case "WaitObject", "WaitCondition", "WaitConditionAnd", "WaitConditionOr",
+			"WaitConditionNegate", "WaitTrigger", "WaitTimeout":
+			q.waiting = true
+		case "KeepAlive":
+			keepalive = keepalive || h == "KeepAlive: on"
+		}
+	}
+	if name == "KeepAlive" && q.ls != nil {
+		q.ls.setKeepAlive(keepalive)
+	}
+	return q
+}
+
+// combinatorRules maps the headers combining filters, stats and wait
+// conditions to the headers they combine.
+var combinatorRules = map[string]string{
+	"And": "Filter", "Or": "Filter", "Negate": "Filter",
+	"StatsAnd": "Stats", "StatsOr": "Stats", "StatsNegate": "Stats",
+	"WaitConditionAnd": "WaitCondition", "WaitConditionOr": "WaitCondition",
+	"WaitConditionNegate": "WaitCondition",
+}
+
+// checkCombinators checks the And, Or and Negate headers, and their
+// variants, refer to existing filters, stats or wait conditions.
+func checkCombinators(headers []string) error {
+	depth := map[string]int{}
+	for _, h := range headers {
+		name := headerName(h)
+		rule, ok := combinatorRules[name]
+		if !ok {
+			depth[name]++
+			continue
+		}
+
+		n := 1
+		if !strings.HasSuffix(name, "Negate") {
+			var err error
+			if n, err = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(h, name+":"))); err != nil {
+				return fmt.Errorf("invalid query header %q", h)
+			}
+		}
+		if n > depth[rule] {
+			return fmt.Errorf("query header %q refers to %d %s headers, only %d available", h, n, rule, depth[rule])
+		}
+		depth[rule] -= n - 1
+	}
+	return nil
+}
+
+func headerName(h string) string {
+	if i := strings.Index(h, ":"); i != -1 {
+		return h[:i]
+	}
+	return h
+}
+
 // Exec executes the query.
 func (q *Query) Exec() (*Response, error) {
+	return q.ExecContext(context.Background())
+}
+
+// ExecContext executes the query, aborting the connection if the context is
+// cancelled or its deadline expires before the response has been read.
+// Responses are served from the cache of the Livestatus instance when it has
+// one, except for queries using any of the Wait headers. Queries are first
+// validated against the schema of the instance once it has been loaded.
+func (q *Query) ExecContext(ctx context.Context) (*Response, error) {
+	if q.ls == nil {
+		return nil, ErrUnboundQuery
+	}
+	if q.err != nil {
+		return nil, q.err
+	}
+	if q.ls.schema != nil {
+		if err := q.ls.schema.Validate(q); err != nil {
+			return nil, err
+		}
+	}
+	if q.ls.cache != nil && !q.waiting {
+		return q.ls.cache.get(ctx, q)
+	}
+	return q.send(ctx)
+}
+
+// send executes the query through the breaker of the instance, failing fast
+// while its circuit is open.
+func (q *Query) send(ctx context.Context) (*Response, error) {
+	if q.ls.breaker == nil {
+		return q.execRetry(ctx)
+	}
+	if err := q.ls.breaker.allow(ctx, q.ls); err != nil {
+		return nil, err
+	}
+	resp, err := q.execRetry(ctx)
+	q.ls.breaker.record(err)
+	return resp, err
+}
+
+// execRetry executes the query, retrying it as set by the retry policy of the
+// instance.
+func (q *Query) execRetry(ctx context.Context) (*Response, error) {
+	if q.ls.retry == nil {
+		return q.exec(ctx)
+	}
+
+	var resp *Response
+	err := q.ls.retry.do(ctx, "query", q.table, func() error {
+		var err error
+		resp, err = q.exec(ctx)
+		return err
+	})
+	return resp, err
+}
+
+func (q *Query) exec(ctx context.Context) (*Response, error) {
 	var err error
 	var conn net.Conn
 
+	if strings.ContainsAny(q.table, "\r\n") {
+		return nil, ErrLineBreak
+	}
+	for _, h := range q.headers {
+		if strings.ContainsAny(h, "\r\n") {
+			return nil, ErrLineBreak
+		}
+	}
+
 	resp := &Response{}
 	st := time.Now()
 	size := 0
@@ -186,32 +447,63 @@ func (q *Query) Exec() (*Response, error) {
 		}
 	}()
 
-	if q.ls.keepConn != nil {
-		conn = q.ls.keepConn
+	conn, keep := q.ls.takeConn()
+	if conn != nil {
 		connectReuseCount.
 			WithLabelValues(conn.RemoteAddr().String()).
 			Inc()
 	} else {
 		// Connect to socket
-		conn, err = q.dial()
+		conn, err = q.dial(ctx)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if !q.ls.keepalive {
-		q.ls.keepConn = nil
-		defer conn.Close()
-	} else {
-		q.ls.keepConn = conn
+	// Connections are only kept once the response has been fully read
+	read := false
+	defer func() {
+		if keep && read {
+			q.ls.putConn(conn)
+			return
+		}
+		conn.Close()
+	}()
+
+	// Unblock any pending read or write once the context is done
+	if ctx.Done() != nil {
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		defer func() {
+			close(stop)
+			<-done
+			// Clear any deadline set as the query completed, as the
+			// connection may be kept alive
+			conn.SetDeadline(time.Time{})
+		}()
+		go func() {
+			defer close(done)
+			select {
+			case <-ctx.Done():
+				conn.SetDeadline(time.Now())
+			case <-stop:
+			}
+		}()
 	}
 
 	// Send command data
-	conn.Write([]byte(q.buildCmd()))
+	if _, err = conn.Write([]byte(q.buildCmd())); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, err
+	}
 
 	data := make([]byte, 16)
-	_, err = conn.Read(data)
-	if err != nil {
+	if _, err = io.ReadFull(conn, data); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
 		return nil, err
 	}
 
@@ -219,36 +511,41 @@ func (q *Query) Exec() (*Response, error) {
 	if err != nil {
 		return nil, err
 	}
+	length, err := strconv.Atoi(strings.TrimSpace(string(data[4:15])))
+	if err != nil {
+		return nil, err
+	}
 
-	for {
-		data = make([]byte, 1024)
-
-		n, err := conn.Read(data)
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return nil, err
+	// Read exactly the length given by the header, so that connections
+	// kept alive can be reused for further queries
+	if _, err = io.CopyN(buf, conn, int64(length)); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
 		}
+		return nil, err
+	}
+	read = true
 
-		buf.Write(bytes.TrimRight(data, "\x00"))
-
-		// New line signals the end of content. This check helps
-		// if the connection is not forcibly closed
-		if data[n-1] == byte('\n') {
-			break
-		}
+	if resp.Status != 200 {
+		err = &StatusError{Status: resp.Status, Message: strings.TrimSpace(buf.String())}
+		return nil, err
 	}
 
 	if buf.Len() == 0 {
+		q.describe(resp)
 		return resp, nil
 	}
 	size = buf.Len()
 
 	// Parse received data for records
-	resp.Records, err = q.parse(buf.Bytes())
+	resp.Rows, err = q.parseRows(buf.Bytes())
 	if err != nil {
 		return nil, err
 	}
+	if !q.rowsOnly {
+		resp.Records = q.records(resp.Rows)
+	}
+	q.describe(resp)
 
 	return resp, nil
 }
@@ -269,7 +566,7 @@ func (q *Query) buildCmd() string {
 	return cmd
 }
 
-func (q *Query) dial() (c net.Conn, err error) {
+func (q *Query) dial(ctx context.Context) (c net.Conn, err error) {
 	defer func() {
 		if err == nil {
 			connectCount.
@@ -285,15 +582,23 @@ func (q *Query) dial() (c net.Conn, err error) {
 	if q.ls.dialer != nil {
 		return q.ls.dialer()
 	} else {
-		return net.Dial(q.ls.network, q.ls.address)
+		d := net.Dialer{}
+		return d.DialContext(ctx, q.ls.network, q.ls.address)
 	}
 }
 
 func (q *Query) parse(data []byte) ([]Record, error) {
-	var (
-		records []Record
-		rows    [][]interface{}
-	)
+	rows, err := q.parseRows(data)
+	if err != nil {
+		return nil, err
+	}
+	return q.records(rows), nil
+}
+
+// parseRows returns the rows of a response body, taking the column names from
+// the first row when they are sent.
+func (q *Query) parseRows(data []byte) ([][]interface{}, error) {
+	var rows [][]interface{}
 
 	// Unmarshal received data
 	if err := json.Unmarshal(data, &rows); err != nil {
@@ -303,32 +608,63 @@ func (q *Query) parse(data []byte) ([]Record, error) {
 			str = string(data[0:127]) + "..."
 		}
 		return nil, errors.New(str)
-	} else if len(q.columns) == 0 && len(rows) < 2 || len(q.columns) > 0 && len(rows) < 1 {
-		return records, nil
 	}
 
-	// Extract columns names from first row
-	start := 0
-
-	if len(q.columns) == 0 {
+	// Column names are sent first unless columns or stats were requested
+	if len(q.columns) == 0 && !q.stats && len(rows) > 0 {
 		q.columns = make([]string, len(rows[0]))
 		for i, value := range rows[0] {
-			q.columns[i] = value.(string)
+			q.columns[i], _ = value.(string)
 		}
-
-		start = 1
+		rows = rows[1:]
 	}
 
-	// Fill records maps
-	for _, row := range rows[start:] {
-		r := make(Record)
+	return rows, nil
+}
+
+// records returns the rows as records mapping column names to values.
+func (q *Query) records(rows [][]interface{}) []Record {
+	var records []Record
+	for _, row := range rows {
+		r := make(Record, len(row))
 		for i, value := range row {
-			r.set(q.columns[i], value)
+			r.set(q.columnName(i), value)
 		}
 		records = append(records, r)
 	}
+	return records
+}
 
-	return records, nil
+// columnName returns the name of the i-th column of a response row. Columns
+// beyond those requested hold stats results.
+func (q *Query) columnName(i int) string {
+	if i < len(q.columns) {
+		return q.columns[i]
+	}
+	return fmt.Sprintf("stats_%d", i-len(q.columns)+1)
+}
+
+// describe sets the columns of a response, and their types when the schema
+// is known.
+func (q *Query) describe(resp *Response) {
+	n := len(q.columns)
+	if len(resp.Rows) > 0 && len(resp.Rows[0]) > n {
+		n = len(resp.Rows[0])
+	}
+
+	resp.Columns = make([]string, n)
+	for i := range resp.Columns {
+		resp.Columns[i] = q.columnName(i)
+	}
+
+	if q.ls.schema != nil {
+		resp.Types = make([]string, n)
+		for i, c := range resp.Columns {
+			if col, ok := q.ls.schema.Column(q.table, c); ok {
+				resp.Types[i] = col.Type
+			}
+		}
+	}
 }
 
 func newQuery(table string, ls *Livestatus) *Query {
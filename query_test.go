@@ -1,7 +1,12 @@
 package livestatus
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net"
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -196,3 +201,338 @@ func Test_QueryParseColumns(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func Test_QueryStats(t *testing.T) {
+	expected := "GET table1\n"
+	expected += "Columns: column1\n"
+	expected += "Stats: state = 0\n"
+	expected += "Stats: state = 1\n"
+	expected += "Stats: acknowledged = 1\n"
+	expected += "StatsNegate:\n"
+	expected += "StatsOr: 2\n"
+	expected += "Stats: state = 2\n"
+	expected += "StatsAnd: 1\n"
+	expected += "ResponseHeader: fixed16\n"
+	expected += "OutputFormat: json\n"
+	expected += "\n"
+
+	q := newQuery("table1", &Livestatus{})
+	q.Columns("column1")
+	q.Stats("state = 0")
+	q.Stats("state = 1")
+	q.Stats("acknowledged = 1")
+	q.StatsNegate()
+	q.StatsOr(2)
+	q.Stats("state = 2")
+	q.StatsAnd(1)
+
+	result := q.buildCmd()
+	if result != expected {
+		t.Logf("\nExpected %q\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_QueryParseStats(t *testing.T) {
+	data := `[
+		["name1", 12, 3],
+		["name2", 45, 6]
+	]`
+
+	expected := []Record{
+		Record{"name": "name1", "stats_1": 12.0, "stats_2": 3.0},
+		Record{"name": "name2", "stats_1": 45.0, "stats_2": 6.0},
+	}
+
+	q := newQuery("table1", &Livestatus{})
+	q.Columns("name")
+	q.Stats("state = 0")
+	q.Stats("state != 0")
+
+	result, err := q.parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_QueryStatusError(t *testing.T) {
+	ls, _ := newFakeLivestatus(fixtureHandler(nil))
+
+	_, err := ls.Query("nosuchtable").Exec()
+
+	expected := &StatusError{Status: 404, Message: "Invalid GET request, no such table 'nosuchtable'"}
+	if !reflect.DeepEqual(err, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, err)
+		t.Fail()
+	}
+}
+
+func Test_QueryParseStatsOnly(t *testing.T) {
+	expected := []Record{
+		Record{"stats_1": 12.0, "stats_2": 3.0},
+	}
+
+	q := newQuery("table1", &Livestatus{})
+	q.Stats("state = 0")
+	q.Stats("state != 0")
+
+	result, err := q.parse([]byte(`[[12, 3]]`))
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_QueryClone(t *testing.T) {
+	base := newQuery("services", &Livestatus{}).Columns("host_name", "description")
+
+	var wg sync.WaitGroup
+	results := make([]string, 3)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			q := base.With(func(q *Query) {
+				q.Filter(fmt.Sprintf("state = %d", i))
+			})
+			results[i] = q.buildCmd()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		expected := fmt.Sprintf("GET services\nColumns: host_name description\nFilter: state = %d\nResponseHeader: fixed16\nOutputFormat: json\n\n", i)
+		if result != expected {
+			t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+			t.Fail()
+		}
+	}
+
+	c := base.Clone().Columns("state")
+	if !reflect.DeepEqual(base.columns, []string{"host_name", "description"}) || len(base.headers) != 1 {
+		t.Logf("\nExpected the base query to be unchanged\nbut got  %#v\n", base.headers)
+		t.Fail()
+	}
+	if !reflect.DeepEqual(c.columns, []string{"state"}) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", []string{"state"}, c.columns)
+		t.Fail()
+	}
+}
+
+func Test_QueryReplaceHeader(t *testing.T) {
+	q := newQuery("hosts", &Livestatus{}).
+		Columns("name").
+		Limit(10).
+		Filter("state = 1").
+		Limit(20)
+
+	q.ReplaceHeader("Limit", "50")
+	expected := []string{"Columns: name", "Limit: 50", "Filter: state = 1"}
+	if !reflect.DeepEqual(q.headers, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, q.headers)
+		t.Fail()
+	}
+
+	q.RemoveHeader("Limit").RemoveHeader("Columns").ReplaceHeader("Stats", "state = 0")
+	expected = []string{"Filter: state = 1", "Stats: state = 0"}
+	if !reflect.DeepEqual(q.headers, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, q.headers)
+		t.Fail()
+	}
+	if q.columns != nil || !q.stats {
+		t.Logf("\nExpected no columns and stats\nbut got  %#v, %t\n", q.columns, q.stats)
+		t.Fail()
+	}
+}
+
+func Test_QueryRemoveHeaderCombined(t *testing.T) {
+	ls, srv := newFakeLivestatus(fixtureHandler(map[string]string{"hosts": `[]`}))
+	q := ls.Query("hosts").
+		Filter("state = 1").
+		Filter("state = 2").
+		Or(2)
+
+	expected := append([]string{}, q.headers...)
+	q.RemoveHeader("Filter").ReplaceHeader("Filter", "state = 0")
+	if !reflect.DeepEqual(q.headers, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, q.headers)
+		t.Fail()
+	}
+	if _, err := q.Exec(); err == nil || len(srv.Queries()) != 0 {
+		t.Logf("\nExpected an error and no query\nbut got  %#v, %q\n", err, srv.Queries())
+		t.Fail()
+	}
+
+	q = ls.Query("hosts").Filter("state = 1").Filter("state = 2").Or(2)
+	q.RemoveHeader("Or").RemoveHeader("Filter")
+	if len(q.headers) != 0 {
+		t.Logf("\nExpected no headers\nbut got  %#v\n", q.headers)
+		t.Fail()
+	}
+	if _, err := q.Exec(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_QueryHeaderFlags(t *testing.T) {
+	ls := &Livestatus{}
+	q := ls.Query("hosts").Columns("name").Columns("state").KeepAlive()
+
+	q.ReplaceHeader("Limit", "10")
+	if expected := []string{"state"}; !reflect.DeepEqual(q.columns, expected) || !ls.keepalive {
+		t.Logf("\nExpected %#v and keepalive\nbut got  %#v, %t\n", expected, q.columns, ls.keepalive)
+		t.Fail()
+	}
+
+	q.RemoveHeader("KeepAlive")
+	if ls.keepalive {
+		t.Logf("\nExpected keepalive to be disabled\n")
+		t.Fail()
+	}
+	q.ReplaceHeader("KeepAlive", "on")
+	if !ls.keepalive {
+		t.Logf("\nExpected keepalive to be enabled\n")
+		t.Fail()
+	}
+}
+
+func Test_QueryCloneExec(t *testing.T) {
+	ls, srv := newFakeLivestatus(fixtureHandler(map[string]string{
+		"services": `[["db1","Disk"]]`,
+	}))
+	base := ls.Query("services").Columns("host_name", "description")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			q := base.With(func(q *Query) {
+				q.Filter(fmt.Sprintf("state = %d", i%4))
+			})
+			_, errs[i] = q.Exec()
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(srv.Queries()); n != len(errs) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", len(errs), n)
+		t.Fail()
+	}
+}
+
+func Test_QueryLineBreak(t *testing.T) {
+	ls, srv := newFakeLivestatus(fixtureHandler(nil))
+
+	if _, err := ls.Query("hosts").Filter("state = 0\nAuthUser: admin").Exec(); err != ErrLineBreak {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrLineBreak, err)
+		t.Fail()
+	}
+
+	c := ls.Command()
+	c.Raw("ACKNOWLEDGE_HOST_PROBLEM")
+	c.Arg("db1\nCOMMAND [0] SHUTDOWN_PROGRAM")
+	if _, err := c.Exec(); err != ErrLineBreak {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrLineBreak, err)
+		t.Fail()
+	}
+
+	if len(srv.Queries()) != 0 || len(srv.Commands()) != 0 {
+		t.Logf("\nExpected nothing sent\nbut got  %q %q\n", srv.Queries(), srv.Commands())
+		t.Fail()
+	}
+}
+
+func Test_QueryKeepAlive(t *testing.T) {
+	ls, dials := flakyLivestatus(0, fixtureHandler(map[string]string{"hosts": `[["db1"]]`}))
+	defer ls.Close()
+
+	// The connection is kept open, responses are read up to their length
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		resp, err := ls.Query("hosts").Columns("name").KeepAlive().ExecContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Record{Record{"name": "db1"}}
+		if !reflect.DeepEqual(resp.Records, expected) {
+			t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Records)
+			t.Fail()
+		}
+
+		// Done contexts don't affect the connection once the query completed
+		cancel()
+		ctx = context.Background()
+	}
+
+	if dials() != 1 {
+		t.Logf("\nExpected a single connection\nbut got  %d\n", dials())
+		t.Fail()
+	}
+}
+
+// closeRecorder is a connection recording whether it was closed.
+type closeRecorder struct {
+	net.Conn
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return c.Conn.Close()
+}
+
+func Test_QueryWriteError(t *testing.T) {
+	var conn *closeRecorder
+	ls := NewLivestatusWithDialer(func() (net.Conn, error) {
+		client, server := net.Pipe()
+		server.Close()
+		conn = &closeRecorder{Conn: client}
+		return conn, nil
+	})
+
+	_, err := ls.Query("hosts").Columns("name").KeepAlive().Exec()
+	if err != io.ErrClosedPipe {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", io.ErrClosedPipe, err)
+		t.Fail()
+	}
+	if !conn.closed {
+		t.Logf("\nExpected the connection to be closed\n")
+		t.Fail()
+	}
+}
+
+func Test_QueryAuthUser(t *testing.T) {
+	expected := "GET hosts\nColumns: name\nAuthUser: admin\nResponseHeader: fixed16\nOutputFormat: json\n\n"
+
+	result := newQuery("hosts", &Livestatus{}).Columns("name").AuthUser("admin").buildCmd()
+	if result != expected {
+		t.Logf("\nExpected %q\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_CommandContext(t *testing.T) {
+	ls := NewLivestatus("tcp", "localhost:6557")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := ls.Command()
+	c.Raw("DISABLE_NOTIFICATIONS")
+	if _, err := c.ExecContext(ctx); err != context.Canceled {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", context.Canceled, err)
+		t.Fail()
+	}
+}
@@ -8,6 +8,41 @@ import (
 // Record is query response entry.
 type Record map[string]interface{}
 
+// HostState is an entry of list columns such as hosts_with_state or
+// members_with_state.
+type HostState struct {
+	Name           string
+	State          int64
+	HasBeenChecked bool
+}
+
+// ServiceState is an entry of list columns such as services_with_state or
+// services_with_info. Output is only set for the `_with_info` variants.
+type ServiceState struct {
+	Description    string
+	State          int64
+	HasBeenChecked bool
+	Output         string
+}
+
+// DowntimeInfo is an entry of the downtimes_with_info list column.
+type DowntimeInfo struct {
+	ID      int64
+	Author  string
+	Comment string
+}
+
+// CommentInfo is an entry of the comments_with_info or
+// comments_with_extra_info list columns. EntryType and EntryTime are only set
+// for the `_with_extra_info` variant.
+type CommentInfo struct {
+	ID        int64
+	Author    string
+	Comment   string
+	EntryType int64
+	EntryTime time.Time
+}
+
 // Len returns the number of columns present in the record.
 func (r Record) Len() int {
 	return len(r)
@@ -65,6 +100,22 @@ func (r Record) GetFloat(name string) (float64, error) {
 	return vc, nil
 }
 
+// GetDuration returns a duration for a specific column holding a number of
+// seconds, such as latency or execution_time.
+//
+// Returns an error if the column is unknown or if the value can't be represented as a duration.
+func (r Record) GetDuration(name string) (time.Duration, error) {
+	v, err := r.Get(name)
+	if err != nil {
+		return 0, err
+	}
+	vc, ok := v.(float64)
+	if !ok {
+		return 0, ErrInvalidValue
+	}
+	return time.Duration(vc * float64(time.Second)), nil
+}
+
 // GetInt returns an integer value for a specific column.
 //
 // Returns an error if the column is unknown or if the value can't be represented as an integer.
@@ -125,6 +176,251 @@ func (r Record) GetTime(name string) (time.Time, error) {
 	return time.Unix(int64(vc), 0), nil
 }
 
+// GetStrings returns a slice of strings for a specific column.
+//
+// Returns an error if the column is unknown or if the value can't be represented as a slice of strings.
+func (r Record) GetStrings(name string) ([]string, error) {
+	vs, err := r.GetSlice(name)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, len(vs))
+	for i, v := range vs {
+		vc, ok := v.(string)
+		if !ok {
+			return nil, ErrInvalidValue
+		}
+		res[i] = vc
+	}
+	return res, nil
+}
+
+// GetInts returns a slice of integers for a specific column.
+//
+// Returns an error if the column is unknown or if the value can't be represented as a slice of integers.
+func (r Record) GetInts(name string) ([]int64, error) {
+	vs, err := r.GetSlice(name)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int64, len(vs))
+	for i, v := range vs {
+		vc, ok := v.(float64)
+		if !ok {
+			return nil, ErrInvalidValue
+		}
+		res[i] = int64(vc)
+	}
+	return res, nil
+}
+
+// GetStringMap returns a map of strings for a specific column, such as
+// custom_variables. Both the JSON object form and the list of pairs form
+// returned by older Livestatus versions are accepted.
+//
+// Returns an error if the column is unknown or if the value can't be represented as a map of strings.
+func (r Record) GetStringMap(name string) (map[string]string, error) {
+	v, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string)
+
+	switch vc := v.(type) {
+	case map[string]interface{}:
+		for k, e := range vc {
+			ec, ok := e.(string)
+			if !ok {
+				return nil, ErrInvalidValue
+			}
+			res[k] = ec
+		}
+	case []interface{}:
+		for _, e := range vc {
+			t, err := tuple(e, 2, 2)
+			if err != nil {
+				return nil, err
+			}
+			k, ok := t[0].(string)
+			if !ok {
+				return nil, ErrInvalidValue
+			}
+			ev, ok := t[1].(string)
+			if !ok {
+				return nil, ErrInvalidValue
+			}
+			res[k] = ev
+		}
+	default:
+		return nil, ErrInvalidValue
+	}
+
+	return res, nil
+}
+
+// GetHostsWithState returns a slice of host states for a specific column,
+// such as members_with_state on the hostgroups table.
+//
+// Returns an error if the column is unknown or if the value can't be represented as a slice of host states.
+func (r Record) GetHostsWithState(name string) ([]HostState, error) {
+	vs, err := r.GetSlice(name)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]HostState, len(vs))
+	for i, v := range vs {
+		t, err := tuple(v, 3, 3)
+		if err != nil {
+			return nil, err
+		}
+		if res[i].Name, err = tupleString(t, 0); err != nil {
+			return nil, err
+		}
+		if res[i].State, err = tupleInt(t, 1); err != nil {
+			return nil, err
+		}
+		if res[i].HasBeenChecked, err = tupleBool(t, 2); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// GetServicesWithState returns a slice of service states for a specific
+// column, such as services_with_state or services_with_info.
+//
+// Returns an error if the column is unknown or if the value can't be represented as a slice of service states.
+func (r Record) GetServicesWithState(name string) ([]ServiceState, error) {
+	vs, err := r.GetSlice(name)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]ServiceState, len(vs))
+	for i, v := range vs {
+		t, err := tuple(v, 3, 4)
+		if err != nil {
+			return nil, err
+		}
+		if res[i].Description, err = tupleString(t, 0); err != nil {
+			return nil, err
+		}
+		if res[i].State, err = tupleInt(t, 1); err != nil {
+			return nil, err
+		}
+		if res[i].HasBeenChecked, err = tupleBool(t, 2); err != nil {
+			return nil, err
+		}
+		if len(t) > 3 {
+			if res[i].Output, err = tupleString(t, 3); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// GetDowntimesWithInfo returns a slice of downtime details for a specific
+// column, such as downtimes_with_info.
+//
+// Returns an error if the column is unknown or if the value can't be represented as a slice of downtime details.
+func (r Record) GetDowntimesWithInfo(name string) ([]DowntimeInfo, error) {
+	vs, err := r.GetSlice(name)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]DowntimeInfo, len(vs))
+	for i, v := range vs {
+		t, err := tuple(v, 3, 3)
+		if err != nil {
+			return nil, err
+		}
+		if res[i].ID, err = tupleInt(t, 0); err != nil {
+			return nil, err
+		}
+		if res[i].Author, err = tupleString(t, 1); err != nil {
+			return nil, err
+		}
+		if res[i].Comment, err = tupleString(t, 2); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// GetCommentsWithInfo returns a slice of comment details for a specific
+// column, such as comments_with_info or comments_with_extra_info.
+//
+// Returns an error if the column is unknown or if the value can't be represented as a slice of comment details.
+func (r Record) GetCommentsWithInfo(name string) ([]CommentInfo, error) {
+	vs, err := r.GetSlice(name)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]CommentInfo, len(vs))
+	for i, v := range vs {
+		t, err := tuple(v, 3, 5)
+		if err != nil {
+			return nil, err
+		}
+		if res[i].ID, err = tupleInt(t, 0); err != nil {
+			return nil, err
+		}
+		if res[i].Author, err = tupleString(t, 1); err != nil {
+			return nil, err
+		}
+		if res[i].Comment, err = tupleString(t, 2); err != nil {
+			return nil, err
+		}
+		if len(t) == 4 {
+			return nil, ErrInvalidValue
+		}
+		if len(t) == 5 {
+			if res[i].EntryType, err = tupleInt(t, 3); err != nil {
+				return nil, err
+			}
+			var ts int64
+			if ts, err = tupleInt(t, 4); err != nil {
+				return nil, err
+			}
+			res[i].EntryTime = time.Unix(ts, 0)
+		}
+	}
+	return res, nil
+}
+
 func (r Record) set(name string, v interface{}) {
 	r[name] = v
 }
+
+func tuple(v interface{}, min, max int) ([]interface{}, error) {
+	t, ok := v.([]interface{})
+	if !ok || len(t) < min || len(t) > max {
+		return nil, ErrInvalidValue
+	}
+	return t, nil
+}
+
+func tupleString(t []interface{}, i int) (string, error) {
+	v, ok := t[i].(string)
+	if !ok {
+		return "", ErrInvalidValue
+	}
+	return v, nil
+}
+
+func tupleInt(t []interface{}, i int) (int64, error) {
+	v, ok := t[i].(float64)
+	if !ok {
+		return 0, ErrInvalidValue
+	}
+	return int64(v), nil
+}
+
+func tupleBool(t []interface{}, i int) (bool, error) {
+	v, ok := t[i].(float64)
+	if !ok {
+		return false, ErrInvalidValue
+	}
+	return v == 1, nil
+}
@@ -184,3 +184,232 @@ func Test_RecordGetTime(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func Test_RecordGetDuration(t *testing.T) {
+	record := Record{
+		"name":    "name1",
+		"latency": 1.5,
+	}
+
+	expected := 1500 * time.Millisecond
+
+	result, err := record.GetDuration("latency")
+	if err != nil {
+		t.Fatal(err)
+	} else if result != expected {
+		t.Logf("\nExpected %s\nbut got  %s\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_RecordGetStrings(t *testing.T) {
+	record := Record{
+		"name":    "name1",
+		"members": []interface{}{"value1", "value2"},
+		"mixed":   []interface{}{"value1", 2.0},
+	}
+
+	expected := []string{"value1", "value2"}
+
+	result, err := record.GetStrings("members")
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	if _, err = record.GetStrings("mixed"); err != ErrInvalidValue {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrInvalidValue, err)
+		t.Fail()
+	}
+
+	if _, err = record.GetStrings("unknown"); err != ErrUnknownColumn {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrUnknownColumn, err)
+		t.Fail()
+	}
+}
+
+func Test_RecordGetInts(t *testing.T) {
+	record := Record{
+		"name":      "name1",
+		"downtimes": []interface{}{1.0, 42.0},
+	}
+
+	expected := []int64{1, 42}
+
+	result, err := record.GetInts("downtimes")
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	if _, err = record.GetInts("name"); err != ErrInvalidValue {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrInvalidValue, err)
+		t.Fail()
+	}
+}
+
+func Test_RecordGetStringMap(t *testing.T) {
+	expected := map[string]string{"OWNER": "ops", "TIER": "1"}
+
+	record := Record{
+		"custom_variables": map[string]interface{}{"OWNER": "ops", "TIER": "1"},
+	}
+
+	result, err := record.GetStringMap("custom_variables")
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	record = Record{
+		"custom_variables": []interface{}{
+			[]interface{}{"OWNER", "ops"},
+			[]interface{}{"TIER", "1"},
+		},
+	}
+
+	result, err = record.GetStringMap("custom_variables")
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	record = Record{
+		"custom_variables": "OWNER=ops",
+	}
+
+	if _, err = record.GetStringMap("custom_variables"); err != ErrInvalidValue {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrInvalidValue, err)
+		t.Fail()
+	}
+}
+
+func Test_RecordGetHostsWithState(t *testing.T) {
+	record := Record{
+		"members_with_state": []interface{}{
+			[]interface{}{"host1", 0.0, 1.0},
+			[]interface{}{"host2", 1.0, 0.0},
+		},
+	}
+
+	expected := []HostState{
+		{Name: "host1", State: 0, HasBeenChecked: true},
+		{Name: "host2", State: 1, HasBeenChecked: false},
+	}
+
+	result, err := record.GetHostsWithState("members_with_state")
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_RecordGetServicesWithState(t *testing.T) {
+	record := Record{
+		"services_with_state": []interface{}{
+			[]interface{}{"svc1", 0.0, 1.0},
+			[]interface{}{"svc2", 2.0, 1.0},
+		},
+		"services_with_info": []interface{}{
+			[]interface{}{"svc1", 0.0, 1.0, "OK - all good"},
+		},
+		"broken": []interface{}{
+			[]interface{}{"svc1", 0.0},
+		},
+	}
+
+	expected := []ServiceState{
+		{Description: "svc1", State: 0, HasBeenChecked: true},
+		{Description: "svc2", State: 2, HasBeenChecked: true},
+	}
+
+	result, err := record.GetServicesWithState("services_with_state")
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	expected = []ServiceState{
+		{Description: "svc1", State: 0, HasBeenChecked: true, Output: "OK - all good"},
+	}
+
+	result, err = record.GetServicesWithState("services_with_info")
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	if _, err = record.GetServicesWithState("broken"); err != ErrInvalidValue {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrInvalidValue, err)
+		t.Fail()
+	}
+}
+
+func Test_RecordGetDowntimesWithInfo(t *testing.T) {
+	record := Record{
+		"downtimes_with_info": []interface{}{
+			[]interface{}{12.0, "admin", "maintenance"},
+		},
+	}
+
+	expected := []DowntimeInfo{
+		{ID: 12, Author: "admin", Comment: "maintenance"},
+	}
+
+	result, err := record.GetDowntimesWithInfo("downtimes_with_info")
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_RecordGetCommentsWithInfo(t *testing.T) {
+	record := Record{
+		"comments_with_info": []interface{}{
+			[]interface{}{3.0, "admin", "looking into it"},
+		},
+		"comments_with_extra_info": []interface{}{
+			[]interface{}{3.0, "admin", "looking into it", 1.0, 1.439633040e9},
+		},
+	}
+
+	expected := []CommentInfo{
+		{ID: 3, Author: "admin", Comment: "looking into it"},
+	}
+
+	result, err := record.GetCommentsWithInfo("comments_with_info")
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	expected = []CommentInfo{
+		{ID: 3, Author: "admin", Comment: "looking into it", EntryType: 1, EntryTime: time.Unix(1439633040, 0)},
+	}
+
+	result, err = record.GetCommentsWithInfo("comments_with_extra_info")
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+}
@@ -1,12 +1,219 @@
 package livestatus
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
 // Response is a query response.
 type Response struct {
 	Status  int
 	Records []Record
+
+	// Columns are the names of the columns of the rows, in order, followed
+	// by stats_1, stats_2 and so on for stats.
+	Columns []string
+	// Types are the types of the columns, as given by the schema of the
+	// Livestatus instance once loaded, and empty for stats.
+	Types []string
+	// Rows are the values of the records, in column order. They are only
+	// used by the methods of the response when Records is nil, as with
+	// RowsOnly queries.
+	Rows [][]interface{}
 }
 
 // Len returns the number of records present in the response.
 func (r Response) Len() int {
+	if r.Records == nil {
+		return len(r.Rows)
+	}
 	return len(r.Records)
 }
+
+// Index returns the position of a column in the rows, or -1 when absent.
+func (r Response) Index(col string) int {
+	for i, c := range r.Columns {
+		if c == col {
+			return i
+		}
+	}
+	return -1
+}
+
+// Value returns the value of a column of the i-th record, from its row when
+// records were not built.
+func (r Response) Value(i int, col string) interface{} {
+	if r.Records != nil {
+		if i < len(r.Records) {
+			return r.Records[i][col]
+		}
+		return nil
+	}
+	if j := r.Index(col); j != -1 && i < len(r.Rows) && j < len(r.Rows[i]) {
+		return r.Rows[i][j]
+	}
+	return nil
+}
+
+// record returns the i-th record, built from its row when records were not.
+func (r Response) record(i int) Record {
+	if r.Records != nil {
+		return r.Records[i]
+	}
+	rec := Record{}
+	for j, c := range r.Columns {
+		if j < len(r.Rows[i]) {
+			rec[c] = r.Rows[i][j]
+		}
+	}
+	return rec
+}
+
+// records returns the records of the response, built from the rows when
+// they were not.
+func (r Response) records() []Record {
+	if r.Records != nil {
+		return r.Records
+	}
+	records := make([]Record, len(r.Rows))
+	for i := range r.Rows {
+		records[i] = r.record(i)
+	}
+	return records
+}
+
+// Group is a group of records sharing the same values for some columns.
+type Group struct {
+	// Key holds the values of the grouping columns, in their order.
+	Key     []interface{}
+	Records []Record
+}
+
+// Aggregate summarizes the numeric values of a column. Count is the number
+// of numeric values, the other fields being 0 when there are none.
+type Aggregate struct {
+	Count int
+	Sum   float64
+	Min   float64
+	Max   float64
+	Avg   float64
+}
+
+// Sort sorts the records by the given columns, numerically for numbers, times
+// and booleans and lexically otherwise. Columns prefixed by `-` are sorted in
+// descending order. Rows are sorted along with the records.
+func (r *Response) Sort(cols ...string) {
+	order := make([]int, r.Len())
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		for _, c := range cols {
+			desc := strings.HasPrefix(c, "-")
+			c = strings.TrimPrefix(c, "-")
+
+			cmp := compareValues(r.Value(order[i], c), r.Value(order[j], c))
+			if desc {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+
+	if r.Records != nil {
+		records := make([]Record, len(order))
+		for i, k := range order {
+			records[i] = r.Records[k]
+		}
+		r.Records = records
+	}
+	if len(r.Rows) == len(order) {
+		rows := make([][]interface{}, len(order))
+		for i, k := range order {
+			rows[i] = r.Rows[k]
+		}
+		r.Rows = rows
+	}
+}
+
+// Group groups the records by the values of the given columns, in the order
+// the groups first appear.
+func (r Response) Group(cols ...string) []Group {
+	var groups []Group
+	index := map[string]int{}
+
+	for _, rec := range r.records() {
+		key := make([]interface{}, len(cols))
+		for i, c := range cols {
+			key[i] = rec[c]
+		}
+		k := fmt.Sprintf("%#v", key)
+
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, Group{Key: key})
+		}
+		groups[i].Records = append(groups[i].Records, rec)
+	}
+
+	return groups
+}
+
+// Aggregate summarizes the values of a column over all the records.
+func (r Response) Aggregate(col string) Aggregate {
+	return aggregate(r.records(), col)
+}
+
+// Len returns the number of records in the group.
+func (g Group) Len() int {
+	return len(g.Records)
+}
+
+// Aggregate summarizes the values of a column over the records of the group.
+func (g Group) Aggregate(col string) Aggregate {
+	return aggregate(g.Records, col)
+}
+
+func aggregate(records []Record, col string) Aggregate {
+	var a Aggregate
+	for _, r := range records {
+		v, ok := numericValue(r[col])
+		if !ok {
+			continue
+		}
+		if a.Count == 0 || v < a.Min {
+			a.Min = v
+		}
+		if a.Count == 0 || v > a.Max {
+			a.Max = v
+		}
+		a.Sum += v
+		a.Count++
+	}
+	if a.Count > 0 {
+		a.Avg = a.Sum / float64(a.Count)
+	}
+	return a
+}
+
+// compareValues compares record values, numerically when both are numbers.
+func compareValues(a, b interface{}) int {
+	if na, ok := numericValue(a); ok {
+		if nb, ok := numericValue(b); ok {
+			switch {
+			case na < nb:
+				return -1
+			case na > nb:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(formatValue(a), formatValue(b))
+}
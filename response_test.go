@@ -1,6 +1,9 @@
 package livestatus
 
 import (
+	"context"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -18,3 +21,157 @@ func Test_ResponseLen(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func Test_ResponseSort(t *testing.T) {
+	resp := Response{
+		Records: []Record{
+			Record{"host_name": "web1", "state": 2.0},
+			Record{"host_name": "db10", "state": 0.0},
+			Record{"host_name": "db2", "state": 2.0},
+			Record{"host_name": "db1", "state": 10.0},
+		},
+	}
+
+	resp.Sort("-state", "host_name")
+
+	expected := []string{"db1", "db2", "web1", "db10"}
+	var result []string
+	for _, r := range resp.Records {
+		result = append(result, r["host_name"].(string))
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_ResponseGroup(t *testing.T) {
+	resp := Response{
+		Records: []Record{
+			Record{"host_name": "db1", "state": 0.0, "latency": 0.5},
+			Record{"host_name": "db2", "state": 2.0, "latency": 1.5},
+			Record{"host_name": "db1", "state": 1.0, "latency": 2.0},
+			Record{"host_name": "db1", "state": 2.0, "latency": ""},
+		},
+	}
+
+	groups := resp.Group("host_name")
+	if len(groups) != 2 || groups[0].Len() != 3 || groups[1].Len() != 1 {
+		t.Fatalf("\nExpected 2 groups of 3 and 1 records\nbut got  %#v\n", groups)
+	}
+	if !reflect.DeepEqual(groups[0].Key, []interface{}{"db1"}) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", []interface{}{"db1"}, groups[0].Key)
+		t.Fail()
+	}
+
+	expected := Aggregate{Count: 2, Sum: 2.5, Min: 0.5, Max: 2, Avg: 1.25}
+	if result := groups[0].Aggregate("latency"); !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	expected = Aggregate{Count: 4, Sum: 5, Min: 0, Max: 2, Avg: 1.25}
+	if result := resp.Aggregate("state"); !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	if result := resp.Aggregate("missing"); !reflect.DeepEqual(result, Aggregate{}) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", Aggregate{}, result)
+		t.Fail()
+	}
+}
+
+func Test_ResponseColumns(t *testing.T) {
+	handler := fixtureHandler(map[string]string{
+		"columns": `[["hosts","state","int",""],["hosts","name","string",""]]`,
+		"hosts":   `[["state","name"],[0,"web1"],[1,"db1"]]`,
+	})
+	ls, _ := newFakeLivestatus(func(req string) (int, string) {
+		if strings.Contains(req, "\nColumns: name\n") {
+			return 200, `[["web1",1],["db1",0]]`
+		}
+		return handler(req)
+	})
+
+	resp, err := ls.Query("hosts").Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"state", "name"}
+	if !reflect.DeepEqual(resp.Columns, expected) || resp.Types != nil {
+		t.Logf("\nExpected %#v with no types\nbut got  %#v, %#v\n", expected, resp.Columns, resp.Types)
+		t.Fail()
+	}
+
+	if _, err := ls.Schema(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = ls.Query("hosts").Columns("name").Stats("state = 0").RowsOnly().Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []string{"name", "stats_1"}
+	if !reflect.DeepEqual(resp.Columns, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Columns)
+		t.Fail()
+	}
+	if expected := []string{"string", ""}; !reflect.DeepEqual(resp.Types, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Types)
+		t.Fail()
+	}
+
+	expectedRows := [][]interface{}{{"web1", 1.0}, {"db1", 0.0}}
+	if resp.Records != nil || !reflect.DeepEqual(resp.Rows, expectedRows) {
+		t.Logf("\nExpected %#v and no records\nbut got  %#v, %#v\n", expectedRows, resp.Rows, resp.Records)
+		t.Fail()
+	}
+
+	if resp.Len() != 2 || resp.Index("stats_1") != 1 || resp.Index("state") != -1 || resp.Value(1, "name") != "db1" {
+		t.Logf("\nExpected positional access to the rows\nbut got  %#v\n", resp)
+		t.Fail()
+	}
+
+	resp.Sort("name")
+	expectedRows = [][]interface{}{{"db1", 0.0}, {"web1", 1.0}}
+	if !reflect.DeepEqual(resp.Rows, expectedRows) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expectedRows, resp.Rows)
+		t.Fail()
+	}
+	if groups := resp.Group("stats_1"); len(groups) != 2 || groups[0].Records[0]["name"] != "db1" {
+		t.Logf("\nExpected groups of the rows\nbut got  %#v\n", groups)
+		t.Fail()
+	}
+	expectedAgg := Aggregate{Count: 2, Sum: 1, Min: 0, Max: 1, Avg: 0.5}
+	if result := resp.Aggregate("stats_1"); !reflect.DeepEqual(result, expectedAgg) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expectedAgg, result)
+		t.Fail()
+	}
+}
+
+func Test_ResponseSortRows(t *testing.T) {
+	ls, _ := newFakeLivestatus(fixtureHandler(map[string]string{
+		"hosts": `[["name","state"],["web1",0],["db1",1]]`,
+	}))
+
+	resp, err := ls.Query("hosts").Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Sort("name")
+	for i := 0; i < resp.Len(); i++ {
+		if resp.Rows[i][0] != resp.Records[i]["name"] {
+			t.Logf("\nExpected rows sorted as the records\nbut got  %#v, %#v\n", resp.Rows, resp.Records)
+			t.Fail()
+		}
+	}
+
+	resp.Records = resp.Records[:1]
+	if resp.Len() != 1 || resp.Value(1, "name") != nil {
+		t.Logf("\nExpected the trimmed records to be used\nbut got  %#v\n", resp)
+		t.Fail()
+	}
+}
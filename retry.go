@@ -0,0 +1,115 @@
+package livestatus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	retryCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "livestatus_retry_count",
+		Help: "Count of the livestatus queries and commands retried after a transient failure",
+	}, []string{"type", "name"})
+
+	retryExhaustedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "livestatus_retry_exhausted_count",
+		Help: "Count of the livestatus queries and commands failing after all their attempts",
+	}, []string{"type", "name"})
+)
+
+func init() {
+	prometheus.MustRegister(retryCount)
+	prometheus.MustRegister(retryExhaustedCount)
+}
+
+// RetryPolicy retries queries, and commands marked as idempotent, failing to
+// connect or losing their connection, such as while Nagios reloads. Other
+// errors, including Livestatus error statuses, are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, 100ms by default.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts, 5s by default.
+	MaxBackoff time.Duration
+	// Multiplier is the factor applied to the delay after each retry, 2 by
+	// default.
+	Multiplier float64
+	// Jitter is the fraction of the delay randomly added or removed, such
+	// as 0.2 for delays within 20% of the nominal one.
+	Jitter float64
+}
+
+// SetRetryPolicy sets the policy retrying failed queries and commands, nil
+// disabling retries.
+func (l *Livestatus) SetRetryPolicy(p *RetryPolicy) {
+	l.retry = p
+}
+
+// do calls f until it succeeds, returns an error that is not transient, or
+// the attempts are exhausted. Retries stop early when the context would be
+// done before the next attempt.
+func (p *RetryPolicy) do(ctx context.Context, typ, name string, f func() error) error {
+	backoff := p.InitialBackoff
+	if backoff <= 0 {
+		backoff = 100 * time.Millisecond
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 5 * time.Second
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			if p.MaxAttempts > 1 {
+				retryExhaustedCount.WithLabelValues(typ, name).Inc()
+			}
+			return err
+		}
+
+		delay := time.Duration(float64(backoff) * (1 + p.Jitter*(2*rand.Float64()-1)))
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return err
+		}
+
+		retryCount.WithLabelValues(typ, name).Inc()
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		}
+
+		backoff = time.Duration(float64(backoff) * multiplier)
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// retryable reports whether an error is transient: a failure to connect, or
+// a connection closed by Livestatus.
+func retryable(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var oe *net.OpError
+	return errors.As(err, &oe) && oe.Op == "dial"
+}
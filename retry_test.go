@@ -0,0 +1,113 @@
+package livestatus
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakyLivestatus returns an instance failing to connect the given number of
+// times, as while the Livestatus socket is missing, and a function returning
+// the number of dials.
+func flakyLivestatus(failures int, handler func(string) (int, string)) (*Livestatus, func() int) {
+	var mu sync.Mutex
+	dials := 0
+
+	_, s := newFakeLivestatus(handler)
+	ls := NewLivestatusWithDialer(func() (net.Conn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dials++
+		if dials <= failures {
+			return nil, &net.OpError{Op: "dial", Net: "unix", Err: syscall.ENOENT}
+		}
+		client, server := net.Pipe()
+		go s.serve(server)
+		return client, nil
+	})
+
+	return ls, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return dials
+	}
+}
+
+func Test_RetryQuery(t *testing.T) {
+	ls, dials := flakyLivestatus(2, fixtureHandler(map[string]string{"hosts": `[["name"],["db1"]]`}))
+	ls.SetRetryPolicy(&RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Jitter: 0.5})
+
+	resp, err := ls.Query("hosts").Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Record{Record{"name": "db1"}}
+	if !reflect.DeepEqual(resp.Records, expected) || dials() != 3 {
+		t.Logf("\nExpected %#v after 3 dials\nbut got  %#v after %d\n", expected, resp.Records, dials())
+		t.Fail()
+	}
+
+	// Attempts are exhausted
+	ls, dials = flakyLivestatus(5, fixtureHandler(nil))
+	ls.SetRetryPolicy(&RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	if _, err := ls.Query("hosts").Exec(); err == nil || dials() != 3 {
+		t.Logf("\nExpected an error after 3 dials\nbut got  %#v after %d\n", err, dials())
+		t.Fail()
+	}
+
+	// Error statuses are not transient
+	ls, dials = flakyLivestatus(0, fixtureHandler(nil))
+	ls.SetRetryPolicy(&RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	if _, err := ls.Query("hosts").Exec(); err == nil || dials() != 1 {
+		t.Logf("\nExpected an error after 1 dial\nbut got  %#v after %d\n", err, dials())
+		t.Fail()
+	}
+}
+
+func Test_RetryDeadline(t *testing.T) {
+	ls, dials := flakyLivestatus(5, fixtureHandler(nil))
+	ls.SetRetryPolicy(&RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour})
+
+	// The backoff exceeds the deadline, so the query gives up at once with
+	// the error of its first attempt
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if _, err := ls.Query("hosts").ExecContext(ctx); err == nil || dials() != 1 {
+		t.Logf("\nExpected an error after 1 dial\nbut got  %#v after %d\n", err, dials())
+		t.Fail()
+	}
+	if err := ctx.Err(); err != nil {
+		t.Logf("\nExpected to give up before the deadline\nbut got  %#v\n", err)
+		t.Fail()
+	}
+}
+
+func Test_RetryCommand(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	ls, dials := flakyLivestatus(1, fixtureHandler(nil))
+	ls.SetRetryPolicy(policy)
+	c := ls.Command()
+	c.Raw("DISABLE_NOTIFICATIONS")
+	if _, err := c.Exec(); err == nil || dials() != 1 {
+		t.Logf("\nExpected an error after 1 dial\nbut got  %#v after %d\n", err, dials())
+		t.Fail()
+	}
+
+	ls, dials = flakyLivestatus(1, fixtureHandler(nil))
+	ls.SetRetryPolicy(policy)
+	c = ls.Command()
+	c.Raw("DISABLE_NOTIFICATIONS")
+	c.Idempotent()
+	if _, err := c.Exec(); err != nil || dials() != 2 {
+		t.Logf("\nExpected no error after 2 dials\nbut got  %#v after %d\n", err, dials())
+		t.Fail()
+	}
+	ls.Close()
+}
@@ -0,0 +1,243 @@
+package livestatus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// SchemaColumn describes a column, as reported by the columns table. Type is
+// one of int, float, string, list, time or dict.
+type SchemaColumn struct {
+	Table       string `json:"table"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// Schema describes the tables and columns of a Livestatus instance.
+type Schema struct {
+	columns []SchemaColumn
+	tables  map[string]map[string]SchemaColumn
+}
+
+// statsAggregates are the operations of the Stats headers aggregating the
+// values of a column.
+var statsAggregates = map[string]bool{
+	"sum": true, "min": true, "max": true, "avg": true, "std": true, "suminv": true, "avginv": true,
+}
+
+// NewSchema creates a schema from column descriptions.
+func NewSchema(columns []SchemaColumn) *Schema {
+	s := &Schema{
+		columns: append([]SchemaColumn{}, columns...),
+		tables:  map[string]map[string]SchemaColumn{},
+	}
+	for _, c := range columns {
+		if s.tables[c.Table] == nil {
+			s.tables[c.Table] = map[string]SchemaColumn{}
+		}
+		s.tables[c.Table][c.Name] = c
+	}
+	return s
+}
+
+// ReadSchema reads a schema from a JSON dump of the columns table, either as
+// written by MarshalJSON or as returned by Livestatus with column headers.
+func ReadSchema(r io.Reader) (*Schema, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	var columns []SchemaColumn
+	if err := json.Unmarshal(raw, &columns); err == nil {
+		return NewSchema(columns), nil
+	}
+
+	var rows [][]interface{}
+	if err := json.Unmarshal(raw, &rows); err != nil {
+		return nil, fmt.Errorf("invalid schema dump, %v", err)
+	}
+	if len(rows) == 0 {
+		return NewSchema(nil), nil
+	}
+
+	q := newQuery("columns", &Livestatus{})
+	records, err := q.parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	return NewSchema(schemaColumns(records)), nil
+}
+
+// LoadSchema reads the schema of a Livestatus instance from its columns
+// table.
+func LoadSchema(ctx context.Context, l *Livestatus) (*Schema, error) {
+	resp, err := l.Query("columns").Columns("table", "name", "type", "description").ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewSchema(schemaColumns(resp.Records)), nil
+}
+
+// Schema returns the schema of the instance, loading it on first use. Once
+// loaded, queries are validated against it before being sent.
+func (l *Livestatus) Schema(ctx context.Context) (*Schema, error) {
+	if l.schema != nil {
+		return l.schema, nil
+	}
+	s, err := LoadSchema(ctx, l)
+	if err != nil {
+		return nil, err
+	}
+	l.schema = s
+	return s, nil
+}
+
+func schemaColumns(records []Record) []SchemaColumn {
+	columns := make([]SchemaColumn, 0, len(records))
+	for _, r := range records {
+		c := SchemaColumn{}
+		c.Table, _ = r.GetString("table")
+		c.Name, _ = r.GetString("name")
+		c.Type, _ = r.GetString("type")
+		c.Description, _ = r.GetString("description")
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+// Tables returns the names of the tables, sorted.
+func (s *Schema) Tables() []string {
+	var names []string
+	for name := range s.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Columns returns the columns of a table, in the order they were described.
+func (s *Schema) Columns(table string) []SchemaColumn {
+	var res []SchemaColumn
+	for _, c := range s.columns {
+		if c.Table == table {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// Column returns the description of a column of a table.
+func (s *Schema) Column(table, name string) (SchemaColumn, bool) {
+	c, ok := s.tables[table][name]
+	return c, ok
+}
+
+// Validate checks that the table of a query and the columns referred to by
+// its columns, filters, stats and wait conditions exist.
+func (s *Schema) Validate(q *Query) error {
+	cols, ok := s.tables[q.table]
+	if !ok {
+		return &SchemaError{Table: q.table}
+	}
+
+	for _, h := range q.headers {
+		i := strings.Index(h, ": ")
+		if i == -1 {
+			continue
+		}
+		fields := strings.Fields(h[i+2:])
+		if len(fields) == 0 {
+			continue
+		}
+
+		var refs []string
+		switch h[:i] {
+		case "Columns":
+			refs = fields
+		case "Filter", "WaitCondition":
+			refs = fields[:1]
+		case "Stats":
+			if len(fields) == 2 && statsAggregates[fields[0]] {
+				refs = fields[1:]
+			} else {
+				refs = fields[:1]
+			}
+		}
+
+		for _, name := range refs {
+			if _, ok := cols[name]; !ok {
+				return &SchemaError{Table: q.table, Column: name}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Decode returns the values of a record of a table converted according to
+// the type of their column: int64 for int, float64 for float, time.Time for
+// time, string, []interface{} for list and map[string]string for dict.
+// Columns unknown to the schema are returned as is.
+func (s *Schema) Decode(table string, r Record) (map[string]interface{}, error) {
+	res := make(map[string]interface{}, len(r))
+	for name, v := range r {
+		c, ok := s.tables[table][name]
+		if !ok {
+			res[name] = v
+			continue
+		}
+
+		var err error
+		switch c.Type {
+		case "int":
+			res[name], err = r.GetInt(name)
+		case "float":
+			res[name], err = r.GetFloat(name)
+		case "time":
+			res[name], err = r.GetTime(name)
+		case "string":
+			res[name], err = r.GetString(name)
+		case "list":
+			res[name], err = r.GetSlice(name)
+		case "dict":
+			res[name], err = r.GetStringMap(name)
+		default:
+			res[name] = v
+		}
+		if err != nil {
+			return nil, fmt.Errorf("column %s, %v", name, err)
+		}
+	}
+	return res, nil
+}
+
+// MarshalJSON encodes the schema as a list of column descriptions, which
+// ReadSchema reads back.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	if s.columns == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(s.columns)
+}
+
+// Dump writes a human readable description of the tables and their columns.
+func (s *Schema) Dump(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for i, table := range s.Tables() {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "%s\n", table)
+		for _, c := range s.Columns(table) {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", c.Name, c.Type, c.Description)
+		}
+	}
+	return tw.Flush()
+}
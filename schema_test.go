@@ -0,0 +1,148 @@
+package livestatus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var schemaFixtures = map[string]string{
+	"columns": `[
+		["hosts","name","string","Host name"],
+		["hosts","state","int","The current state"],
+		["hosts","latency","float","Check latency"],
+		["hosts","last_check","time","Time of the last check"],
+		["hosts","groups","list","Host groups"],
+		["hosts","custom_variables","dict","Custom variables"],
+		["status","program_version","string","The version of the monitoring daemon"]
+	]`,
+	"hosts": `[["db1",0]]`,
+}
+
+func Test_SchemaValidate(t *testing.T) {
+	ls, s := newFakeLivestatus(fixtureHandler(schemaFixtures))
+	if _, err := ls.Schema(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[*Query]error{
+		ls.Query("hosts").Columns("name", "state").Filter("groups >= db"): nil,
+		ls.Query("hosts").Stats("state = 1").Stats("sum latency"):         nil,
+		ls.Query("nosuchtable"):                                               &SchemaError{Table: "nosuchtable"},
+		ls.Query("hosts").Columns("name", "stat"):                             &SchemaError{Table: "hosts", Column: "stat"},
+		ls.Query("hosts").Filter("nam = db1"):                                 &SchemaError{Table: "hosts", Column: "nam"},
+		ls.Query("hosts").Stats("avg latenc"):                                 &SchemaError{Table: "hosts", Column: "latenc"},
+		ls.Query("hosts").WaitCondition("stat = 1").WaitObject("db1"):         &SchemaError{Table: "hosts", Column: "stat"},
+		ls.Query("status").Columns("program_version").Filter("name = nagios"): &SchemaError{Table: "status", Column: "name"},
+	}
+
+	for q, expected := range tests {
+		_, err := q.Exec()
+		if !reflect.DeepEqual(err, expected) {
+			t.Logf("\nExpected %#v\nbut got  %#v\n", expected, err)
+			t.Fail()
+		}
+	}
+
+	// Only the schema and the two valid queries reached the server
+	if n := len(s.Queries()); n != 3 {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", 3, n)
+		t.Fail()
+	}
+}
+
+func Test_SchemaDecode(t *testing.T) {
+	ls, _ := newFakeLivestatus(fixtureHandler(schemaFixtures))
+	schema, err := ls.Schema(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := schema.Decode("hosts", Record{
+		"name":             "db1",
+		"state":            2.0,
+		"latency":          0.5,
+		"last_check":       1500000000.0,
+		"groups":           []interface{}{"db"},
+		"custom_variables": map[string]interface{}{"ROLE": "primary"},
+		"unknown":          true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"name":             "db1",
+		"state":            int64(2),
+		"latency":          0.5,
+		"last_check":       time.Unix(1500000000, 0),
+		"groups":           []interface{}{"db"},
+		"custom_variables": map[string]string{"ROLE": "primary"},
+		"unknown":          true,
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	if _, err := schema.Decode("hosts", Record{"state": "up"}); err == nil {
+		t.Logf("\nExpected an error for an invalid value\n")
+		t.Fail()
+	}
+}
+
+func Test_ReadSchema(t *testing.T) {
+	dump := `[["description","name","table","type"],["Host name","name","hosts","string"]]`
+	schema, err := ReadSchema(strings.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []SchemaColumn{{Table: "hosts", Name: "name", Type: "string", Description: "Host name"}}
+	if result := schema.Columns("hosts"); !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	// Marshalled schemas are read back
+	b, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err = ReadSchema(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := schema.Columns("hosts"); !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_SchemaDump(t *testing.T) {
+	schema := NewSchema([]SchemaColumn{
+		{"status", "program_version", "string", "The version"},
+		{"hosts", "name", "string", "Host name"},
+		{"hosts", "state", "int", "The current state"},
+	})
+
+	buf := bytes.NewBuffer(nil)
+	if err := schema.Dump(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "hosts\n" +
+		"  name   string  Host name\n" +
+		"  state  int     The current state\n" +
+		"\n" +
+		"status\n" +
+		"  program_version  string  The version\n"
+	if result := buf.String(); result != expected {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+}
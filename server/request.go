@@ -0,0 +1,246 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+// Request is a parsed Livestatus request, either a GET query on a table or
+// an external command.
+type Request struct {
+	Table   string
+	Command string
+
+	Columns []string
+	Filter  lvst.Filter
+	Stats   []Stat
+	// Limit is the maximum number of records processed, 0 meaning no limit.
+	Limit int
+
+	// ColumnHeaders reports whether the response starts with a row of
+	// column names, which is the default when no columns are requested.
+	ColumnHeaders  bool
+	ResponseHeader string
+	OutputFormat   string
+	KeepAlive      bool
+	// AuthUser is the contact the request is made on behalf of. It is not
+	// enforced by the server, but tables may use it to restrict records.
+	AuthUser string
+}
+
+// Stat is a Stats header, either counting the records matching a filter or
+// aggregating the values of a column.
+type Stat struct {
+	// Op is count for filter stats, or one of sum, min, max, avg, std,
+	// suminv and avginv.
+	Op     string
+	Column string
+	Filter lvst.Filter
+}
+
+var statsOps = map[string]bool{
+	"sum": true, "min": true, "max": true, "avg": true, "std": true, "suminv": true, "avginv": true,
+}
+
+// requestError is an invalid request, reported with a Livestatus status
+// code.
+type requestError struct {
+	status int
+	msg    string
+}
+
+func (e *requestError) Error() string {
+	return e.msg
+}
+
+// RequestStatus returns the Livestatus status code reporting an error
+// returned by ReadRequest.
+func RequestStatus(err error) int {
+	if rerr, ok := err.(*requestError); ok {
+		return rerr.status
+	}
+	return 452
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &requestError{400, fmt.Sprintf(format, args...)}
+}
+
+// ReadRequest reads a request from rd. Queries end with an empty line, while
+// commands are a single line. Headers the server does not handle, such as
+// Localtime, Timelimit, Separators and the Wait headers, are ignored.
+func ReadRequest(rd *bufio.Reader) (*Request, error) {
+	var line string
+	var err error
+
+	// Skip the blank lines some clients send after commands
+	for line == "" {
+		line, err = readLine(rd)
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, err
+		}
+	}
+
+	if strings.HasPrefix(line, "COMMAND ") {
+		return &Request{Command: strings.TrimPrefix(line, "COMMAND ")}, nil
+	}
+	if !strings.HasPrefix(line, "GET ") {
+		return nil, &requestError{452, fmt.Sprintf("Invalid request method %q", line)}
+	}
+
+	req := &Request{
+		Table:          strings.TrimSpace(strings.TrimPrefix(line, "GET ")),
+		ResponseHeader: "off",
+		OutputFormat:   "csv",
+	}
+
+	var (
+		filters     lvst.FilterStack
+		stats       []Stat
+		headersSeen = map[string]bool{}
+	)
+
+	for {
+		// The final empty line is optional before the end of input
+		line, err = readLine(rd)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+
+		i := strings.Index(line, ":")
+		if i == -1 {
+			return nil, badRequest("Invalid header line %q", line)
+		}
+		name, value := line[:i], strings.TrimSpace(line[i+1:])
+		headersSeen[name] = true
+
+		switch name {
+		case "Columns":
+			req.Columns = append(req.Columns, strings.Fields(value)...)
+		case "Filter":
+			f, err := lvst.ParseFilterRule(value)
+			if err != nil {
+				return nil, badRequest("%v", err)
+			}
+			filters.Push(f)
+		case "And", "Or":
+			n, err := strconv.Atoi(value)
+			if err == nil && name == "And" {
+				err = filters.And(n)
+			} else if err == nil {
+				err = filters.Or(n)
+			}
+			if err != nil {
+				return nil, badRequest("Invalid %s header, %v", name, err)
+			}
+		case "Negate":
+			if err := filters.Negate(); err != nil {
+				return nil, badRequest("Invalid Negate header, %v", err)
+			}
+		case "Stats":
+			st, err := parseStat(value)
+			if err != nil {
+				return nil, err
+			}
+			stats = append(stats, st)
+		case "StatsAnd", "StatsOr":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, badRequest("Invalid %s header %q", name, value)
+			}
+			if stats, err = combineStats(stats, n, name == "StatsAnd"); err != nil {
+				return nil, err
+			}
+		case "StatsNegate":
+			if len(stats) == 0 || stats[len(stats)-1].Op != "count" {
+				return nil, badRequest("StatsNegate requires a filter stats header")
+			}
+			stats[len(stats)-1].Filter = lvst.FilterNot{Filter: stats[len(stats)-1].Filter}
+		case "Limit":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, badRequest("Invalid Limit header %q", value)
+			}
+			req.Limit = n
+		case "ColumnHeaders":
+			req.ColumnHeaders = value == "on"
+		case "ResponseHeader":
+			if value != "off" && value != "fixed16" {
+				return nil, badRequest("Invalid ResponseHeader %q", value)
+			}
+			req.ResponseHeader = value
+		case "OutputFormat":
+			if _, ok := formatters[value]; !ok {
+				return nil, badRequest("Unsupported OutputFormat %q", value)
+			}
+			req.OutputFormat = value
+		case "KeepAlive":
+			req.KeepAlive = value == "on"
+		case "AuthUser":
+			req.AuthUser = value
+		default:
+			// Front ends send headers freely, fail on the requests they
+			// make rather than on those
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	req.Filter = filters.Filter()
+	req.Stats = stats
+	if !headersSeen["ColumnHeaders"] {
+		req.ColumnHeaders = len(req.Columns) == 0 && len(req.Stats) == 0
+	}
+
+	return req, nil
+}
+
+func parseStat(s string) (Stat, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 2 && statsOps[fields[0]] {
+		return Stat{Op: fields[0], Column: fields[1]}, nil
+	}
+
+	f, err := lvst.ParseFilterRule(s)
+	if err != nil {
+		return Stat{}, badRequest("%v", err)
+	}
+	return Stat{Op: "count", Filter: f}, nil
+}
+
+// combineStats replaces the n last filter stats by their conjunction or
+// disjunction.
+func combineStats(stats []Stat, n int, and bool) ([]Stat, error) {
+	if n < 1 || n > len(stats) {
+		return nil, badRequest("Cannot combine %d stats, only %d available", n, len(stats))
+	}
+
+	var fs []lvst.Filter
+	for _, st := range stats[len(stats)-n:] {
+		if st.Op != "count" {
+			return nil, badRequest("Cannot combine aggregating stats")
+		}
+		fs = append(fs, st.Filter)
+	}
+
+	st := Stat{Op: "count", Filter: lvst.FilterOr(fs)}
+	if and {
+		st.Filter = lvst.FilterAnd(fs)
+	}
+	return append(stats[:len(stats)-n], st), nil
+}
+
+func readLine(rd *bufio.Reader) (string, error) {
+	line, err := rd.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
@@ -0,0 +1,471 @@
+// Package server implements the Livestatus protocol, so that data sources
+// can be served to existing Livestatus clients and front ends.
+//
+// Tables are registered under a name and return their records for each
+// query. The server takes care of filtering, stats, limits and output
+// formatting, using the same filter model as the client package.
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+// Column describes a table column. Type is one of int, float, string, list,
+// time or dict, as reported by the columns table.
+type Column struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// Table is a data source served as a Livestatus table.
+type Table interface {
+	// Columns describes the columns of the table, in their default order.
+	Columns() []Column
+
+	// Records returns the records of the table. The request is given so
+	// tables may narrow down the records they fetch, but the server
+	// filters the returned records regardless.
+	Records(ctx context.Context, req *Request) ([]lvst.Record, error)
+}
+
+// Server serves tables over the Livestatus protocol.
+type Server struct {
+	// OnCommand is called with each external command received, without its
+	// COMMAND prefix. Commands are ignored when it is not set.
+	OnCommand func(ctx context.Context, cmd string)
+
+	mu     sync.RWMutex
+	tables map[string]Table
+}
+
+// New creates a new server with no tables. The columns table describing the
+// registered tables is always available.
+func New() *Server {
+	return &Server{tables: map[string]Table{}}
+}
+
+// Register serves a table under the given name.
+func (s *Server) Register(name string, t Table) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tables[name] = t
+}
+
+// Serve accepts connections on the listener, serving each of them in a new
+// goroutine, until the listener fails.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(context.Background(), conn)
+	}
+}
+
+// ServeConn serves the requests received on a connection, closing it once
+// done.
+func (s *Server) ServeConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	rd := bufio.NewReader(conn)
+	for {
+		req, err := ReadRequest(rd)
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			// Errors are reported with a header, as the request
+			// could not tell whether one was expected
+			writeResponse(conn, "fixed16", RequestStatus(err), []byte(err.Error()+"\n"))
+			return
+		}
+
+		if req.Command != "" {
+			if s.OnCommand != nil {
+				s.OnCommand(ctx, req.Command)
+			}
+			continue
+		}
+
+		status, body := s.Handle(ctx, req)
+		if err := writeResponse(conn, req.ResponseHeader, status, body); err != nil || !req.KeepAlive {
+			return
+		}
+	}
+}
+
+// Handle executes a query, returning the Livestatus status code and the
+// response body.
+func (s *Server) Handle(ctx context.Context, req *Request) (int, []byte) {
+	t, ok := s.table(req.Table)
+	if !ok {
+		return 404, []byte(fmt.Sprintf("Invalid GET request, no such table '%s'\n", req.Table))
+	}
+
+	known := map[string]bool{}
+	var cols []string
+	for _, c := range t.Columns() {
+		known[c.Name] = true
+		cols = append(cols, c.Name)
+	}
+	for _, c := range req.Columns {
+		if !known[c] {
+			return 404, []byte(fmt.Sprintf("Table '%s' has no column '%s'\n", req.Table, c))
+		}
+	}
+	for _, st := range req.Stats {
+		if st.Op != "count" && !known[st.Column] {
+			return 404, []byte(fmt.Sprintf("Table '%s' has no column '%s'\n", req.Table, st.Column))
+		}
+	}
+	if len(req.Columns) > 0 || len(req.Stats) > 0 {
+		cols = req.Columns
+	}
+
+	records, err := t.Records(ctx, req)
+	if err != nil {
+		return 500, []byte(err.Error() + "\n")
+	}
+
+	var matched []lvst.Record
+	for _, r := range records {
+		if req.Limit > 0 && len(matched) >= req.Limit {
+			break
+		}
+		if req.Filter == nil || req.Filter.Match(r) {
+			matched = append(matched, r)
+		}
+	}
+
+	var rows [][]interface{}
+	if len(req.Stats) > 0 {
+		rows = stats(matched, cols, req.Stats)
+	} else {
+		for _, r := range matched {
+			row := make([]interface{}, len(cols))
+			for i, c := range cols {
+				row[i] = r[c]
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	body, err := EncodeRows(req, cols, rows)
+	if err != nil {
+		return 500, []byte(err.Error() + "\n")
+	}
+	return 200, body
+}
+
+// EncodeRows encodes response rows in the output format of the request,
+// preceded by the column names when requested. Rows hold the values of the
+// given columns followed by those of the request stats.
+func EncodeRows(req *Request, cols []string, rows [][]interface{}) ([]byte, error) {
+	if req.ColumnHeaders {
+		header := make([]interface{}, 0, len(cols)+len(req.Stats))
+		for _, c := range cols {
+			header = append(header, c)
+		}
+		for i := range req.Stats {
+			header = append(header, fmt.Sprintf("stats_%d", i+1))
+		}
+		rows = append([][]interface{}{header}, rows...)
+	}
+
+	out := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		out[i] = make([]interface{}, len(row))
+		for j, v := range row {
+			out[i][j] = normalize(v)
+		}
+	}
+
+	format, ok := formatters[req.OutputFormat]
+	if !ok {
+		format = formatJSON
+	}
+	return format(out)
+}
+
+// WriteResponse writes a response body, preceded by a status header when
+// the request asked for one.
+func WriteResponse(w io.Writer, req *Request, status int, body []byte) error {
+	return writeResponse(w, req.ResponseHeader, status, body)
+}
+
+func (s *Server) table(name string) (Table, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if t, ok := s.tables[name]; ok {
+		return t, true
+	}
+	if name == "columns" {
+		return columnsTable{s}, true
+	}
+	return nil, false
+}
+
+// stats computes the stats of the records, grouped by the values of the
+// given columns.
+func stats(records []lvst.Record, cols []string, sts []Stat) [][]interface{} {
+	type group struct {
+		key     []interface{}
+		records []lvst.Record
+	}
+
+	var groups []*group
+	byKey := map[string]*group{}
+	for _, r := range records {
+		key := make([]interface{}, len(cols))
+		for i, c := range cols {
+			key[i] = r[c]
+		}
+		k := fmt.Sprintf("%#v", key)
+
+		g, ok := byKey[k]
+		if !ok {
+			g = &group{key: key}
+			byKey[k] = g
+			groups = append(groups, g)
+		}
+		g.records = append(g.records, r)
+	}
+
+	// Ungrouped stats always return a row, even when no record matched
+	if len(cols) == 0 && len(groups) == 0 {
+		groups = append(groups, &group{})
+	}
+
+	var rows [][]interface{}
+	for _, g := range groups {
+		row := append([]interface{}{}, g.key...)
+		for _, st := range sts {
+			row = append(row, aggregate(g.records, st))
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func aggregate(records []lvst.Record, st Stat) interface{} {
+	if st.Op == "count" {
+		n := 0
+		for _, r := range records {
+			if st.Filter.Match(r) {
+				n++
+			}
+		}
+		return n
+	}
+
+	var vals []float64
+	for _, r := range records {
+		if v, ok := number(r[st.Column]); ok {
+			vals = append(vals, v)
+		}
+	}
+
+	var sum, suminv, sumsq float64
+	for _, v := range vals {
+		sum += v
+		sumsq += v * v
+		if v != 0 {
+			suminv += 1 / v
+		}
+	}
+	n := float64(len(vals))
+
+	switch st.Op {
+	case "sum":
+		return sum
+	case "suminv":
+		return suminv
+	case "min", "max":
+		if len(vals) == 0 {
+			return 0
+		}
+		sort.Float64s(vals)
+		if st.Op == "min" {
+			return vals[0]
+		}
+		return vals[len(vals)-1]
+	}
+
+	if n == 0 {
+		return 0
+	}
+	switch st.Op {
+	case "avg":
+		return sum / n
+	case "avginv":
+		return suminv / n
+	case "std":
+		return math.Sqrt(math.Max(sumsq/n-(sum/n)*(sum/n), 0))
+	}
+	return 0
+}
+
+// number returns the value of numeric record values.
+func number(v interface{}) (float64, bool) {
+	switch v := normalize(v).(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// normalize converts record values to the types Livestatus clients expect,
+// such as times as seconds since the epoch and booleans as 0 or 1.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case time.Time:
+		return v.Unix()
+	case time.Duration:
+		return v.Seconds()
+	case int32:
+		return int64(v)
+	case uint:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case []string:
+		l := make([]interface{}, len(v))
+		for i, s := range v {
+			l[i] = s
+		}
+		return l
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = normalize(e)
+		}
+		return l
+	}
+	return v
+}
+
+func writeResponse(w io.Writer, header string, status int, body []byte) error {
+	if header == "fixed16" {
+		if _, err := fmt.Fprintf(w, "%03d %11d\n", status, len(body)); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// columnsTable describes the columns of the registered tables.
+type columnsTable struct {
+	s *Server
+}
+
+func (columnsTable) Columns() []Column {
+	return []Column{
+		{"description", "string", "A description of the column"},
+		{"name", "string", "The name of the column within the table"},
+		{"table", "string", "The name of the table"},
+		{"type", "string", "The data type of the column (int, float, string, list)"},
+	}
+}
+
+func (t columnsTable) Records(ctx context.Context, req *Request) ([]lvst.Record, error) {
+	t.s.mu.RLock()
+	defer t.s.mu.RUnlock()
+
+	var names []string
+	for name := range t.s.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var res []lvst.Record
+	for _, name := range names {
+		for _, c := range t.s.tables[name].Columns() {
+			res = append(res, lvst.Record{
+				"description": c.Description,
+				"name":        c.Name,
+				"table":       name,
+				"type":        c.Type,
+			})
+		}
+	}
+	return res, nil
+}
+
+// formatters encode response rows in each supported output format.
+var formatters = map[string]func([][]interface{}) ([]byte, error){
+	"csv":  formatCSV,
+	"json": formatJSON,
+	// Python literals are a superset of the JSON the values encode to
+	"python":  formatJSON,
+	"python3": formatJSON,
+}
+
+func formatCSV(rows [][]interface{}) ([]byte, error) {
+	var b strings.Builder
+	for _, row := range rows {
+		for i, v := range row {
+			if i > 0 {
+				b.WriteByte(';')
+			}
+			b.WriteString(csvValue(v, ","))
+		}
+		b.WriteByte('\n')
+	}
+	return []byte(b.String()), nil
+}
+
+// csvValue formats a value for CSV output, with list elements separated by
+// sep and the fields of nested lists by `|`.
+func csvValue(v interface{}, sep string) string {
+	l, ok := v.([]interface{})
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	strs := make([]string, len(l))
+	for i, e := range l {
+		strs[i] = csvValue(e, "|")
+	}
+	return strings.Join(strs, sep)
+}
+
+func formatJSON(rows [][]interface{}) ([]byte, error) {
+	if rows == nil {
+		rows = [][]interface{}{}
+	}
+	b, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
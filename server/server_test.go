@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lvst "github.com/tcolgate/go-livestatus"
+)
+
+// inventory is a table of static records.
+type inventory []lvst.Record
+
+func (inventory) Columns() []Column {
+	return []Column{
+		{"name", "string", "Name of the machine"},
+		{"state", "int", "Health state"},
+		{"cpus", "int", "Number of CPUs"},
+		{"updated", "time", "Last update time"},
+		{"tags", "list", "Tags of the machine"},
+	}
+}
+
+func (t inventory) Records(ctx context.Context, req *Request) ([]lvst.Record, error) {
+	return t, nil
+}
+
+var machines = inventory{
+	{"name": "db1", "state": 0, "cpus": 8, "updated": time.Unix(1439640000, 0), "tags": []string{"db", "prod"}},
+	{"name": "db2", "state": 2, "cpus": 4, "updated": time.Unix(1439640100, 0), "tags": []string{"db"}},
+	{"name": "web1", "state": 2, "cpus": 2, "updated": time.Unix(1439640200, 0), "tags": []string{"web", "prod"}},
+}
+
+func newClient(s *Server) *lvst.Livestatus {
+	return lvst.NewLivestatusWithDialer(func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go s.ServeConn(context.Background(), server)
+		return client, nil
+	})
+}
+
+func Test_ServerQuery(t *testing.T) {
+	s := New()
+	s.Register("inventory", machines)
+
+	resp, err := newClient(s).Query("inventory").
+		Columns("name", "updated", "tags").
+		Filter("state = 2").
+		Filter("tags >= prod").
+		Filter("name ~ ^db").
+		Or(2).
+		Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []lvst.Record{
+		lvst.Record{"name": "db2", "updated": 1439640100.0, "tags": []interface{}{"db"}},
+		lvst.Record{"name": "web1", "updated": 1439640200.0, "tags": []interface{}{"web", "prod"}},
+	}
+	if !reflect.DeepEqual(resp.Records, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Records)
+		t.Fail()
+	}
+}
+
+func Test_ServerQueryAllColumns(t *testing.T) {
+	s := New()
+	s.Register("inventory", machines)
+
+	resp, err := newClient(s).Query("inventory").Limit(1).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []lvst.Record{
+		lvst.Record{"name": "db1", "state": 0.0, "cpus": 8.0, "updated": 1439640000.0, "tags": []interface{}{"db", "prod"}},
+	}
+	if !reflect.DeepEqual(resp.Records, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Records)
+		t.Fail()
+	}
+}
+
+func Test_ServerStats(t *testing.T) {
+	s := New()
+	s.Register("inventory", machines)
+
+	resp, err := newClient(s).Query("inventory").
+		Columns("state").
+		Stats("sum cpus").
+		Stats("tags >= prod").
+		Stats("name ~ ^web").
+		StatsNegate().
+		Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []lvst.Record{
+		lvst.Record{"state": 0.0, "stats_1": 8.0, "stats_2": 1.0, "stats_3": 1.0},
+		lvst.Record{"state": 2.0, "stats_1": 6.0, "stats_2": 1.0, "stats_3": 1.0},
+	}
+	if !reflect.DeepEqual(resp.Records, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Records)
+		t.Fail()
+	}
+
+	resp, err = newClient(s).Query("inventory").
+		Stats("state = 0").
+		Stats("cpus > 2").
+		StatsAnd(2).
+		Stats("avg cpus").
+		Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []lvst.Record{
+		lvst.Record{"stats_1": 1.0, "stats_2": 14.0 / 3},
+	}
+	if !reflect.DeepEqual(resp.Records, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Records)
+		t.Fail()
+	}
+}
+
+func Test_ServerErrors(t *testing.T) {
+	s := New()
+	s.Register("inventory", machines)
+	ls := newClient(s)
+
+	tests := map[*lvst.Query]int{
+		ls.Query("nosuchtable"):                    404,
+		ls.Query("inventory").Columns("nosuchcol"): 404,
+		ls.Query("inventory").Filter("state == 1"): 400,
+		ls.Query("inventory").And(2):               400,
+	}
+
+	for q, expected := range tests {
+		_, err := q.Exec()
+		if serr, ok := err.(*lvst.StatusError); !ok || serr.Status != expected {
+			t.Logf("\nExpected status %d\nbut got  %#v\n", expected, err)
+			t.Fail()
+		}
+	}
+}
+
+func Test_ServerIgnoredHeaders(t *testing.T) {
+	s := New()
+	s.Register("inventory", machines)
+
+	resp, err := newClient(s).Query("inventory").
+		Columns("name").
+		Filter("state = 0").
+		WaitTrigger("all").
+		WaitTimeout(10*time.Second).
+		ReplaceHeader("Localtime", "1439640000").
+		ReplaceHeader("Timelimit", "10").
+		Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []lvst.Record{lvst.Record{"name": "db1"}}
+	if !reflect.DeepEqual(resp.Records, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Records)
+		t.Fail()
+	}
+}
+
+func Test_ServerColumnsTable(t *testing.T) {
+	s := New()
+	s.Register("inventory", machines)
+
+	resp, err := newClient(s).Query("columns").Columns("table", "name", "type").Filter("type = time").Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []lvst.Record{lvst.Record{"table": "inventory", "name": "updated", "type": "time"}}
+	if !reflect.DeepEqual(resp.Records, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, resp.Records)
+		t.Fail()
+	}
+}
+
+func Test_ServerCSVKeepAlive(t *testing.T) {
+	s := New()
+	s.Register("inventory", machines)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go s.ServeConn(context.Background(), server)
+
+	go func() {
+		client.Write([]byte("GET inventory\nColumns: name tags\nFilter: cpus >= 4\nKeepAlive: on\n\n"))
+		client.Write([]byte("GET inventory\nStats: state = 2\n\n"))
+	}()
+
+	rd := bufio.NewReader(client)
+	var lines []string
+	for i := 0; i < 3; i++ {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+
+	expected := []string{"db1;db,prod\n", "db2;db\n", "2\n"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expected, lines)
+		t.Fail()
+	}
+}
+
+func Test_ServerCommand(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		cmds []string
+	)
+
+	s := New()
+	s.OnCommand = func(ctx context.Context, cmd string) {
+		mu.Lock()
+		cmds = append(cmds, cmd)
+		mu.Unlock()
+	}
+	s.Register("inventory", machines)
+
+	ls := newClient(s)
+	c := ls.Command()
+	c.Raw("DISABLE_NOTIFICATIONS")
+	if _, err := c.Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The following query is sent on the same connection
+	if _, err := ls.Query("inventory").Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(cmds) != 1 || !strings.HasSuffix(cmds[0], "] DISABLE_NOTIFICATIONS;") {
+		t.Logf("\nExpected DISABLE_NOTIFICATIONS command\nbut got  %q\n", cmds)
+		t.Fail()
+	}
+}
@@ -0,0 +1,231 @@
+package livestatus
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+var stateHistoryColumns = []string{
+	"host_name", "service_description", "from", "until", "duration", "duration_part",
+	"state", "in_downtime", "in_host_downtime", "is_flapping", "in_notification_period",
+	"log_output",
+}
+
+var stateHistorySums = []string{
+	"duration_ok", "duration_warning", "duration_critical", "duration_unknown", "duration_unmonitored",
+	"duration_part_ok", "duration_part_warning", "duration_part_critical", "duration_part_unknown",
+	"duration_part_unmonitored",
+}
+
+// StateHistoryEntry is a period during which a host or service stayed in the
+// same state, as found in the statehist table. Service is empty for hosts.
+type StateHistoryEntry struct {
+	Host                 string
+	Service              string
+	From                 time.Time
+	Until                time.Time
+	Duration             time.Duration
+	DurationPart         float64
+	State                int64
+	InDowntime           bool
+	InHostDowntime       bool
+	IsFlapping           bool
+	InNotificationPeriod bool
+	LogOutput            string
+}
+
+// StateHistorySummary is the time a host or service spent in each state over
+// the queried range, along with the fraction of the range it represents.
+// Hosts report their UP time as OK, DOWN as Warning and UNREACHABLE as
+// Critical.
+type StateHistorySummary struct {
+	Host    string
+	Service string
+
+	OK          time.Duration
+	Warning     time.Duration
+	Critical    time.Duration
+	Unknown     time.Duration
+	Unmonitored time.Duration
+
+	OKPart          float64
+	WarningPart     float64
+	CriticalPart    float64
+	UnknownPart     float64
+	UnmonitoredPart float64
+}
+
+// StateHistoryQuery is a query on the statehist table provided by Checkmk,
+// which always covers a time range.
+type StateHistoryQuery struct {
+	ls      *Livestatus
+	start   time.Time
+	end     time.Time
+	filters []string
+}
+
+// StateHistory creates a new query on the statehist table over the range from
+// start to end.
+func (l *Livestatus) StateHistory(start, end time.Time) *StateHistoryQuery {
+	return &StateHistoryQuery{
+		ls:    l,
+		start: start,
+		end:   end,
+	}
+}
+
+// Host restricts the query to a specific host and its services.
+func (s *StateHistoryQuery) Host(name string) *StateHistoryQuery {
+	return s.Filter("host_name = " + name)
+}
+
+// Service restricts the query to a specific service.
+func (s *StateHistoryQuery) Service(host, desc string) *StateHistoryQuery {
+	return s.Filter("host_name = " + host).Filter("service_description = " + desc)
+}
+
+// HostsOnly restricts the query to the history of the hosts themselves.
+func (s *StateHistoryQuery) HostsOnly() *StateHistoryQuery {
+	return s.Filter("service_description =")
+}
+
+// Filter adds a new filter to the query.
+func (s *StateHistoryQuery) Filter(rule string) *StateHistoryQuery {
+	s.filters = append(s.filters, rule)
+	return s
+}
+
+// Exec executes the query, returning the state periods in the range.
+func (s *StateHistoryQuery) Exec() ([]StateHistoryEntry, error) {
+	return s.ExecContext(context.Background())
+}
+
+// ExecContext executes the query, returning the state periods in the range.
+func (s *StateHistoryQuery) ExecContext(ctx context.Context) ([]StateHistoryEntry, error) {
+	q, err := s.query(stateHistoryColumns...)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := s.exec(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]StateHistoryEntry, len(rs))
+	for i, r := range rs {
+		e := &res[i]
+		if e.Host, err = r.GetString("host_name"); err != nil {
+			return nil, err
+		}
+		if e.Service, err = r.GetString("service_description"); err != nil {
+			return nil, err
+		}
+		if e.From, err = r.GetTime("from"); err != nil {
+			return nil, err
+		}
+		if e.Until, err = r.GetTime("until"); err != nil {
+			return nil, err
+		}
+		if e.Duration, err = r.GetDuration("duration"); err != nil {
+			return nil, err
+		}
+		if e.DurationPart, err = r.GetFloat("duration_part"); err != nil {
+			return nil, err
+		}
+		if e.State, err = r.GetInt("state"); err != nil {
+			return nil, err
+		}
+		if e.InDowntime, err = r.GetBool("in_downtime"); err != nil {
+			return nil, err
+		}
+		if e.InHostDowntime, err = r.GetBool("in_host_downtime"); err != nil {
+			return nil, err
+		}
+		if e.IsFlapping, err = r.GetBool("is_flapping"); err != nil {
+			return nil, err
+		}
+		if e.InNotificationPeriod, err = r.GetBool("in_notification_period"); err != nil {
+			return nil, err
+		}
+		if e.LogOutput, err = r.GetString("log_output"); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// Summary executes the query aggregated per host and service, returning the
+// time spent in each state over the range.
+func (s *StateHistoryQuery) Summary() ([]StateHistorySummary, error) {
+	return s.SummaryContext(context.Background())
+}
+
+// SummaryContext executes the query aggregated per host and service,
+// returning the time spent in each state over the range.
+func (s *StateHistoryQuery) SummaryContext(ctx context.Context) ([]StateHistorySummary, error) {
+	q, err := s.query("host_name", "service_description")
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range stateHistorySums {
+		q.Stats("sum " + c)
+	}
+
+	rs, err := s.exec(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]StateHistorySummary, len(rs))
+	for i, r := range rs {
+		sum := &res[i]
+		if sum.Host, err = r.GetString("host_name"); err != nil {
+			return nil, err
+		}
+		if sum.Service, err = r.GetString("service_description"); err != nil {
+			return nil, err
+		}
+
+		durs := []*time.Duration{&sum.OK, &sum.Warning, &sum.Critical, &sum.Unknown, &sum.Unmonitored}
+		parts := []*float64{&sum.OKPart, &sum.WarningPart, &sum.CriticalPart, &sum.UnknownPart, &sum.UnmonitoredPart}
+		for j, d := range durs {
+			if *d, err = r.GetDuration(fmt.Sprintf("stats_%d", j+1)); err != nil {
+				return nil, err
+			}
+		}
+		for j, p := range parts {
+			if *p, err = r.GetFloat(fmt.Sprintf("stats_%d", len(durs)+j+1)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func (s *StateHistoryQuery) query(cols ...string) (*Query, error) {
+	if !s.end.After(s.start) {
+		return nil, fmt.Errorf("invalid state history range %s - %s", s.start, s.end)
+	}
+
+	q := s.ls.Query("statehist")
+	q.Columns(cols...)
+	q.Filter(fmt.Sprintf("time >= %d", s.start.Unix()))
+	q.Filter(fmt.Sprintf("time < %d", s.end.Unix()))
+	for _, f := range s.filters {
+		q.Filter(f)
+	}
+
+	return q, nil
+}
+
+func (s *StateHistoryQuery) exec(ctx context.Context, q *Query) ([]Record, error) {
+	resp, err := q.ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Records, nil
+}
@@ -0,0 +1,137 @@
+package livestatus
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var stateHistoryFixture = `[
+	["db1","",1439629440,1439633040,3600,0.5,0,0,0,0,1,"PING OK"],
+	["db1","Disk",1439629440,1439631240,1800,0.25,2,1,0,1,1,"DISK CRITICAL - /var 95%"]
+]`
+
+var stateHistorySummaryFixture = `[
+	["db1","",7200,0,0,0,0,1,0,0,0,0],
+	["db1","Disk",3600,1800,1800,0,0,0.5,0.25,0.25,0,0]
+]`
+
+func stateHistoryHandler(req string) (int, string) {
+	if strings.Contains(req, "Stats:") {
+		return 200, stateHistorySummaryFixture
+	}
+	return 200, stateHistoryFixture
+}
+
+func Test_StateHistory(t *testing.T) {
+	ls, srv := newFakeLivestatus(stateHistoryHandler)
+
+	start := time.Unix(1439626800, 0)
+	end := time.Unix(1439634000, 0)
+
+	result, err := ls.StateHistory(start, end).Host("db1").Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []StateHistoryEntry{
+		StateHistoryEntry{
+			Host:                 "db1",
+			From:                 time.Unix(1439629440, 0),
+			Until:                time.Unix(1439633040, 0),
+			Duration:             time.Hour,
+			DurationPart:         0.5,
+			InNotificationPeriod: true,
+			LogOutput:            "PING OK",
+		},
+		StateHistoryEntry{
+			Host:                 "db1",
+			Service:              "Disk",
+			From:                 time.Unix(1439629440, 0),
+			Until:                time.Unix(1439631240, 0),
+			Duration:             30 * time.Minute,
+			DurationPart:         0.25,
+			State:                2,
+			InDowntime:           true,
+			IsFlapping:           true,
+			InNotificationPeriod: true,
+			LogOutput:            "DISK CRITICAL - /var 95%",
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	expectedQuery := "GET statehist\n"
+	expectedQuery += "Columns: " + strings.Join(stateHistoryColumns, " ") + "\n"
+	expectedQuery += "Filter: time >= 1439626800\n"
+	expectedQuery += "Filter: time < 1439634000\n"
+	expectedQuery += "Filter: host_name = db1\n"
+	expectedQuery += "ResponseHeader: fixed16\n"
+	if q := srv.Queries()[0]; !strings.HasPrefix(q, expectedQuery) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expectedQuery, q)
+		t.Fail()
+	}
+}
+
+func Test_StateHistorySummary(t *testing.T) {
+	ls, srv := newFakeLivestatus(stateHistoryHandler)
+
+	start := time.Unix(1439626800, 0)
+	end := time.Unix(1439634000, 0)
+
+	result, err := ls.StateHistory(start, end).Service("db1", "Disk").Summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []StateHistorySummary{
+		StateHistorySummary{
+			Host:   "db1",
+			OK:     2 * time.Hour,
+			OKPart: 1,
+		},
+		StateHistorySummary{
+			Host:         "db1",
+			Service:      "Disk",
+			OK:           time.Hour,
+			Warning:      30 * time.Minute,
+			Critical:     30 * time.Minute,
+			OKPart:       0.5,
+			WarningPart:  0.25,
+			CriticalPart: 0.25,
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	expectedQuery := "GET statehist\n"
+	expectedQuery += "Columns: host_name service_description\n"
+	expectedQuery += "Filter: time >= 1439626800\n"
+	expectedQuery += "Filter: time < 1439634000\n"
+	expectedQuery += "Filter: host_name = db1\n"
+	expectedQuery += "Filter: service_description = Disk\n"
+	expectedQuery += "Stats: sum duration_ok\n"
+	if q := srv.Queries()[0]; !strings.HasPrefix(q, expectedQuery) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expectedQuery, q)
+		t.Fail()
+	}
+}
+
+func Test_StateHistoryRange(t *testing.T) {
+	ls, srv := newFakeLivestatus(stateHistoryHandler)
+
+	now := time.Now()
+	if _, err := ls.StateHistory(now, now.Add(-time.Hour)).Exec(); err == nil {
+		t.Log("\nExpected error for inverted range\n")
+		t.Fail()
+	}
+	if n := len(srv.Queries()); n != 0 {
+		t.Logf("\nExpected no query to be sent\nbut got  %d\n", n)
+		t.Fail()
+	}
+}
@@ -0,0 +1,275 @@
+package livestatus
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default key columns identifying the objects of the watchable tables.
+var watchKeys = map[string][]string{
+	"hosts":         {"name"},
+	"services":      {"host_name", "description"},
+	"hostgroups":    {"name"},
+	"servicegroups": {"name"},
+	"contacts":      {"name"},
+	"contactgroups": {"name"},
+}
+
+// WatchEvent is a change to a watched object. Old is nil for objects that
+// appeared since the last query, and New is nil for objects that
+// disappeared.
+type WatchEvent struct {
+	Key string
+	Old Record
+	New Record
+}
+
+// Watcher repeatedly issues wait queries on a table and reports changes to
+// the watched columns of the selected objects.
+type Watcher struct {
+	ls      *Livestatus
+	table   string
+	columns []string
+	keys    []string
+	objects []string
+	filters []string
+	trigger string
+	timeout time.Duration
+	retry   time.Duration
+	onError func(error)
+}
+
+// Watch creates a new watcher on a specific table. By default every object of
+// the table is watched, waiting on the `all` trigger for up to 10 seconds
+// between queries.
+func (l *Livestatus) Watch(table string) *Watcher {
+	return &Watcher{
+		ls:      l,
+		table:   table,
+		keys:    watchKeys[table],
+		trigger: "all",
+		timeout: 10 * time.Second,
+		retry:   5 * time.Second,
+	}
+}
+
+// Columns sets the names of the columns compared to detect a change.
+func (w *Watcher) Columns(names ...string) *Watcher {
+	w.columns = names
+	return w
+}
+
+// Keys sets the names of the columns identifying an object. It is only
+// required for tables other than hosts, services, hostgroups, servicegroups,
+// contacts and contactgroups.
+func (w *Watcher) Keys(names ...string) *Watcher {
+	w.keys = names
+	return w
+}
+
+// Objects restricts the watch to a set of objects, named as for
+// Query.WaitObject. When a single object is watched, the wait queries also
+// return as soon as one of its watched columns changes.
+func (w *Watcher) Objects(objs ...string) *Watcher {
+	w.objects = objs
+	return w
+}
+
+// Filter adds a filter selecting the objects to watch.
+func (w *Watcher) Filter(rule string) *Watcher {
+	w.filters = append(w.filters, rule)
+	return w
+}
+
+// Trigger sets the nagios event that ends each wait query.
+func (w *Watcher) Trigger(event string) *Watcher {
+	w.trigger = event
+	return w
+}
+
+// Timeout sets the maximum time each wait query blocks for.
+func (w *Watcher) Timeout(t time.Duration) *Watcher {
+	w.timeout = t
+	return w
+}
+
+// RetryInterval sets the delay before querying again after a failed query.
+func (w *Watcher) RetryInterval(t time.Duration) *Watcher {
+	w.retry = t
+	return w
+}
+
+// OnError sets a function called with the error of every failed query.
+// Failed queries are otherwise retried silently.
+func (w *Watcher) OnError(f func(error)) *Watcher {
+	w.onError = f
+	return w
+}
+
+// Run starts watching, delivering change events on the returned channel until
+// the context is cancelled, at which point the channel is closed. Changes are
+// reported relative to the first successful query, which produces no events.
+func (w *Watcher) Run(ctx context.Context) (<-chan WatchEvent, error) {
+	if len(w.keys) == 0 {
+		return nil, ErrNoWatchKeys
+	}
+
+	ch := make(chan WatchEvent)
+	go w.run(ctx, ch)
+
+	return ch, nil
+}
+
+func (w *Watcher) run(ctx context.Context, ch chan<- WatchEvent) {
+	defer close(ch)
+
+	var last map[string]Record
+
+	for ctx.Err() == nil {
+		rs, err := w.query(ctx, last)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if w.onError != nil {
+				w.onError(err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(w.retry):
+			}
+			continue
+		}
+
+		if last != nil {
+			for _, ev := range w.diff(last, rs) {
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+		last = rs
+	}
+}
+
+// query fetches the current state of the watched objects, waiting for a
+// change when a previous state is known.
+func (w *Watcher) query(ctx context.Context, last map[string]Record) (map[string]Record, error) {
+	q := w.ls.Query(w.table)
+	q.Columns(w.allColumns()...)
+
+	for _, f := range w.filters {
+		q.Filter(f)
+	}
+	for _, o := range w.objects {
+		vals := strings.SplitN(o, " ", len(w.keys))
+		if len(vals) != len(w.keys) {
+			return nil, fmt.Errorf("invalid watch object %q", o)
+		}
+		for i, k := range w.keys {
+			q.Filter(k + " = " + vals[i])
+		}
+		if len(w.keys) > 1 {
+			q.And(len(w.keys))
+		}
+	}
+	if len(w.objects) > 1 {
+		q.Or(len(w.objects))
+	}
+
+	if last != nil {
+		q.WaitTrigger(w.trigger)
+		q.WaitTimeout(w.timeout)
+		if len(w.objects) == 1 {
+			q.WaitObject(w.objects[0])
+			w.waitConditions(q, last[w.objects[0]])
+		}
+	}
+
+	resp, err := q.ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := make(map[string]Record, len(resp.Records))
+	for _, r := range resp.Records {
+		rs[w.key(r)] = r
+	}
+	return rs, nil
+}
+
+// waitConditions makes the wait query return as soon as any scalar watched
+// column differs from its last known value.
+func (w *Watcher) waitConditions(q *Query, r Record) {
+	n := 0
+	for _, c := range w.columns {
+		switch v := r[c].(type) {
+		case string:
+			q.WaitCondition(c + " != " + v)
+		case float64:
+			q.WaitCondition(c + " != " + strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			continue
+		}
+		n++
+	}
+	if n > 1 {
+		q.WaitConditionOr(n)
+	}
+}
+
+func (w *Watcher) diff(last, cur map[string]Record) []WatchEvent {
+	var evs []WatchEvent
+
+	for k, r := range cur {
+		old, ok := last[k]
+		if !ok {
+			evs = append(evs, WatchEvent{Key: k, New: r})
+			continue
+		}
+		for _, c := range w.columns {
+			if !reflect.DeepEqual(old[c], r[c]) {
+				evs = append(evs, WatchEvent{Key: k, Old: old, New: r})
+				break
+			}
+		}
+	}
+	for k, r := range last {
+		if _, ok := cur[k]; !ok {
+			evs = append(evs, WatchEvent{Key: k, Old: r})
+		}
+	}
+	sort.Slice(evs, func(i, j int) bool { return evs[i].Key < evs[j].Key })
+
+	return evs
+}
+
+func (w *Watcher) allColumns() []string {
+	cols := append([]string{}, w.keys...)
+	for _, c := range w.columns {
+		found := false
+		for _, k := range w.keys {
+			found = found || k == c
+		}
+		if !found {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+func (w *Watcher) key(r Record) string {
+	vals := make([]string, len(w.keys))
+	for i, k := range w.keys {
+		vals[i] = fmt.Sprintf("%v", r[k])
+	}
+	return strings.Join(vals, " ")
+}
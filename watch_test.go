@@ -0,0 +1,146 @@
+package livestatus
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Watch(t *testing.T) {
+	responses := []string{
+		`[["db1","Disk",0],["db1","Load",0]]`,
+		"",
+		`[["db1","Disk",2],["db1","Load",0]]`,
+		`[["db1","Disk",2],["db1","Load",0]]`,
+		`[["db1","Disk",2],["web1","HTTP",0]]`,
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	n := 0
+	ls, _ := newFakeLivestatus(func(req string) (int, string) {
+		if n >= len(responses) {
+			<-done
+			return 200, "[]"
+		}
+		resp := responses[n]
+		n++
+		if resp == "" {
+			return 500, "Internal error"
+		}
+		return 200, resp
+	})
+
+	var errs []error
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := ls.Watch("services").
+		Columns("state").
+		RetryInterval(time.Millisecond).
+		OnError(func(err error) { errs = append(errs, err) }).
+		Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []WatchEvent{
+		WatchEvent{
+			Key: "db1 Disk",
+			Old: Record{"host_name": "db1", "description": "Disk", "state": 0.0},
+			New: Record{"host_name": "db1", "description": "Disk", "state": 2.0},
+		},
+		WatchEvent{
+			Key: "db1 Load",
+			Old: Record{"host_name": "db1", "description": "Load", "state": 0.0},
+		},
+		WatchEvent{
+			Key: "web1 HTTP",
+			New: Record{"host_name": "web1", "description": "HTTP", "state": 0.0},
+		},
+	}
+
+	var result []WatchEvent
+	for range expected {
+		select {
+		case ev := <-ch:
+			result = append(result, ev)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for watch events")
+		}
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+
+	if len(errs) != 1 {
+		t.Logf("\nExpected 1 error\nbut got  %#v\n", errs)
+		t.Fail()
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("expected watch channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for watch to stop")
+	}
+}
+
+func Test_WatchObject(t *testing.T) {
+	ls, srv := newFakeLivestatus(func(req string) (int, string) {
+		return 200, `[["db1","Disk",0,"OK"]]`
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := ls.Watch("services").
+		Columns("state", "plugin_output").
+		Objects("db1 Disk").
+		Trigger("check").
+		Timeout(5 * time.Second).
+		Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for len(srv.Queries()) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	for range ch {
+	}
+
+	expected := "GET services\n"
+	expected += "Columns: host_name description state plugin_output\n"
+	expected += "Filter: host_name = db1\n"
+	expected += "Filter: description = Disk\n"
+	expected += "And: 2\n"
+	expected += "WaitTrigger: check\n"
+	expected += "WaitTimeout: 5000\n"
+	expected += "WaitObject: db1 Disk\n"
+	expected += "WaitCondition: state != 0\n"
+	expected += "WaitCondition: plugin_output != OK\n"
+	expected += "WaitConditionOr: 2\n"
+
+	result := srv.Queries()[1]
+	if !strings.HasPrefix(result, expected) {
+		t.Logf("\nExpected %q\nbut got  %q\n", expected, result)
+		t.Fail()
+	}
+}
+
+func Test_WatchNoKeys(t *testing.T) {
+	ls, _ := newFakeLivestatus(nil)
+
+	if _, err := ls.Watch("log").Run(context.Background()); err != ErrNoWatchKeys {
+		t.Logf("\nExpected %#v\nbut got  %#v\n", ErrNoWatchKeys, err)
+		t.Fail()
+	}
+}